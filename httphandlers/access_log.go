@@ -0,0 +1,64 @@
+package httphandlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is the format of the plain text access log lines.
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat is the Apache Common Log Format.
+	CommonLogFormat AccessLogFormat = iota
+	// CombinedLogFormat is the Apache Combined Log Format, the Common Log Format followed by the referer and user agent.
+	CombinedLogFormat
+)
+
+// WriteAccessLog creates a handler option that writes the request log to w as plain text access log lines
+// in the given format instead of structured log entries.
+func WriteAccessLog(w io.Writer, format AccessLogFormat) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.accessLog = &accessLogWriter{w: w, format: format}
+	}
+}
+
+type accessLogWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format AccessLogFormat
+}
+
+// write writes a single access log line for the request which started at ts
+func (l *accessLogWriter) write(req *http.Request, ts time.Time, host, username, uri string, status, size int) {
+	if username == "" {
+		username = "-"
+	}
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, host...)
+	buf = append(buf, " - "...)
+	buf = append(buf, username...)
+	buf = append(buf, " ["...)
+	buf = ts.AppendFormat(buf, "02/Jan/2006:15:04:05 -0700")
+	buf = append(buf, "] "...)
+	buf = strconv.AppendQuote(buf, req.Method+" "+uri+" "+req.Proto)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(status), 10)
+	buf = append(buf, ' ')
+	buf = strconv.AppendInt(buf, int64(size), 10)
+	if l.format == CombinedLogFormat {
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, req.Referer())
+		buf = append(buf, ' ')
+		buf = strconv.AppendQuote(buf, req.UserAgent())
+	}
+	buf = append(buf, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(buf)
+}
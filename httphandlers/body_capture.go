@@ -0,0 +1,166 @@
+package httphandlers
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// BodyCapture configures the capture of request and response bodies in the request log.
+// Bodies are captured for the requests matching Routes and StatusClasses, or carrying the debug header.
+// Without Routes and StatusClasses only the requests with the debug header are captured,
+// and without any criteria at all every request is captured.
+type BodyCapture struct {
+	// MaxBytes is the number of bytes captured from each body. Defaults to 2048.
+	MaxBytes int
+	// Routes are the path prefixes of the requests which bodies are captured.
+	Routes []string
+	// StatusClasses are the response status classes which bodies are captured, e.g. 5 for 5xx responses.
+	StatusClasses []int
+	// DebugHeader is the name of the request header enabling the capture for a single request
+	// when its value equals DebugSecret. The header is never logged.
+	DebugHeader string
+	// DebugSecret is the value of DebugHeader enabling the capture. It is required when DebugHeader is set,
+	// so the capture can't be turned on by any client knowing the header name.
+	DebugSecret string
+	// Redact, if set, is applied to every captured body before it is logged.
+	Redact func(contentType string, body []byte) []byte
+}
+
+// CaptureBodies creates a handler option that adds the beginning of the request and response bodies to the log entry
+// as request_body and response_body. Textual bodies are logged as they are, binary ones base64 encoded.
+// The bodies are copied while the inner handler reads and writes them, which is not affected by the capture.
+// It panics if DebugHeader is set without DebugSecret.
+func CaptureBodies(c BodyCapture) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	if c.DebugHeader != "" && c.DebugSecret == "" {
+		panic("httphandlers: CaptureBodies requires a DebugSecret with the DebugHeader " + c.DebugHeader)
+	}
+	return func(h *transactionAwareRequestLoggingHandler) {
+		if c.MaxBytes <= 0 {
+			c.MaxBytes = 2048
+		}
+		h.bodyCapture = &c
+	}
+}
+
+// requested tells whether the bodies of the request may be captured, before the response status is known
+func (c *BodyCapture) requested(req *http.Request) bool {
+	if c.debugRequested(req) {
+		return true
+	}
+	if len(c.Routes) == 0 && len(c.StatusClasses) == 0 {
+		return c.DebugHeader == ""
+	}
+	if len(c.Routes) == 0 {
+		return true
+	}
+	for _, r := range c.Routes {
+		if strings.HasPrefix(req.URL.Path, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// captured tells whether the captured bodies are logged once the response status is known
+func (c *BodyCapture) captured(req *http.Request, status int) bool {
+	if !c.requested(req) {
+		return false
+	}
+	if c.debugRequested(req) || len(c.StatusClasses) == 0 {
+		return true
+	}
+	for _, class := range c.StatusClasses {
+		if status/100 == class {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BodyCapture) debugRequested(req *http.Request) bool {
+	if c.DebugHeader == "" || c.DebugSecret == "" {
+		return false
+	}
+	value := req.Header.Get(c.DebugHeader)
+	return subtle.ConstantTimeCompare([]byte(value), []byte(c.DebugSecret)) == 1
+}
+
+// field creates the log field describing the captured body
+func (c *BodyCapture) field(contentType string, b *bodyBuffer) map[string]interface{} {
+	data := b.data
+	if c.Redact != nil {
+		data = c.Redact(contentType, data)
+	}
+
+	field := map[string]interface{}{}
+	if isTextual(contentType) && utf8.Valid(data) {
+		field["content"] = string(data)
+	} else {
+		field["content"] = base64.StdEncoding.EncodeToString(data)
+		field["encoding"] = "base64"
+	}
+	if b.truncated {
+		field["truncated"] = true
+	}
+	return field
+}
+
+// isTextual tells whether the content type can be logged as text.
+// Bodies without a content type are treated as text as long as they are valid UTF-8.
+func isTextual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/x-www-form-urlencoded",
+		mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// bodyBuffer keeps the first bytes written to it up to its limit
+type bodyBuffer struct {
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func (b *bodyBuffer) write(p []byte) {
+	room := b.limit - len(b.data)
+	if len(p) > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.data = append(b.data, p...)
+}
+
+// wrapRequestBody prepares the capture of the request and response bodies if it is required for the request
+// and replaces the request body with a wrapper keeping track of how it is read.
+// It returns the wrapper of the request body or nil if the request has no body.
+func (h transactionAwareRequestLoggingHandler) wrapRequestBody(req *http.Request, w loggingResponseWriter) *requestBodyReader {
+	capture := h.bodyCapture != nil && h.bodyCapture.requested(req)
+	if capture {
+		w.captureBody(h.bodyCapture.MaxBytes)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	body := &requestBodyReader{ReadCloser: req.Body}
+	if capture {
+		body.capture = &bodyBuffer{limit: h.bodyCapture.MaxBytes}
+	}
+	req.Body = body
+	return body
+}
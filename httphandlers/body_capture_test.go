@@ -0,0 +1,160 @@
+package httphandlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type echoHandler struct {
+	Status      int
+	ContentType string
+}
+
+func (h echoHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	if h.ContentType != "" {
+		w.Header().Set("Content-Type", h.ContentType)
+	}
+	w.WriteHeader(h.Status)
+	_, _ = w.Write(body)
+}
+
+func TestCaptureBodies(t *testing.T) {
+	tests := []struct {
+		name             string
+		capture          BodyCapture
+		path             string
+		headers          map[string]string
+		body             string
+		inner            echoHandler
+		expectedRequest  interface{}
+		expectedResponse interface{}
+	}{
+		{
+			name:             "capture everything",
+			capture:          BodyCapture{},
+			path:             "/content",
+			body:             `{"title":"test"}`,
+			inner:            echoHandler{Status: http.StatusOK, ContentType: "application/json"},
+			expectedRequest:  map[string]interface{}{"content": `{"title":"test"}`},
+			expectedResponse: map[string]interface{}{"content": `{"title":"test"}`},
+		},
+		{
+			name:             "truncated body",
+			capture:          BodyCapture{MaxBytes: 4},
+			path:             "/content",
+			body:             "hello world",
+			inner:            echoHandler{Status: http.StatusOK, ContentType: "text/plain"},
+			expectedRequest:  map[string]interface{}{"content": "hell", "truncated": true},
+			expectedResponse: map[string]interface{}{"content": "hell", "truncated": true},
+		},
+		{
+			name:             "binary body",
+			capture:          BodyCapture{},
+			path:             "/images",
+			body:             "\x89PNG",
+			inner:            echoHandler{Status: http.StatusOK, ContentType: "image/png"},
+			expectedRequest:  map[string]interface{}{"content": "iVBORw==", "encoding": "base64"},
+			expectedResponse: map[string]interface{}{"content": "iVBORw==", "encoding": "base64"},
+		},
+		{
+			name:             "route not matching",
+			capture:          BodyCapture{Routes: []string{"/lists"}},
+			path:             "/content",
+			body:             "hello",
+			inner:            echoHandler{Status: http.StatusOK},
+			expectedRequest:  nil,
+			expectedResponse: nil,
+		},
+		{
+			name:             "status class matching",
+			capture:          BodyCapture{StatusClasses: []int{5}},
+			path:             "/content",
+			body:             "hello",
+			inner:            echoHandler{Status: http.StatusBadGateway},
+			expectedRequest:  map[string]interface{}{"content": "hello"},
+			expectedResponse: map[string]interface{}{"content": "hello"},
+		},
+		{
+			name:             "status class not matching",
+			capture:          BodyCapture{Routes: []string{"/content"}, StatusClasses: []int{5}},
+			path:             "/content",
+			body:             "hello",
+			inner:            echoHandler{Status: http.StatusOK},
+			expectedRequest:  nil,
+			expectedResponse: nil,
+		},
+		{
+			name:             "debug header",
+			capture:          BodyCapture{DebugHeader: "X-Debug-Body", DebugSecret: "s3cret"},
+			path:             "/content",
+			headers:          map[string]string{"X-Debug-Body": "s3cret"},
+			body:             "hello",
+			inner:            echoHandler{Status: http.StatusOK},
+			expectedRequest:  map[string]interface{}{"content": "hello"},
+			expectedResponse: map[string]interface{}{"content": "hello"},
+		},
+		{
+			name:             "debug header with wrong secret",
+			capture:          BodyCapture{DebugHeader: "X-Debug-Body", DebugSecret: "s3cret"},
+			path:             "/content",
+			headers:          map[string]string{"X-Debug-Body": "guess"},
+			body:             "hello",
+			inner:            echoHandler{Status: http.StatusOK},
+			expectedRequest:  nil,
+			expectedResponse: nil,
+		},
+		{
+			name: "redaction hook",
+			capture: BodyCapture{Redact: func(contentType string, body []byte) []byte {
+				return bytes.ReplaceAll(body, []byte("secret"), []byte("***"))
+			}},
+			path:             "/content",
+			body:             "password=secret",
+			inner:            echoHandler{Status: http.StatusOK, ContentType: "application/x-www-form-urlencoded"},
+			expectedRequest:  map[string]interface{}{"content": "password=***"},
+			expectedResponse: map[string]interface{}{"content": "password=***"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			req := httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			resp := httptest.NewRecorder()
+
+			handler := TransactionAwareRequestLoggingHandler(log, test.inner, CaptureBodies(test.capture))
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, test.body, resp.Body.String(), "The inner handler should read and write the whole body")
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			assert.Equal(t, test.expectedRequest, entries[0]["request_body"])
+			assert.Equal(t, test.expectedResponse, entries[0]["response_body"])
+			if headers, ok := entries[0]["headers"].(map[string]interface{}); ok {
+				assert.NotContains(t, headers, "X-Debug-Body", "The debug header should not be logged")
+			}
+		})
+	}
+}
+
+func TestCaptureBodiesRequiresDebugSecret(t *testing.T) {
+	assert.Panics(t, func() {
+		CaptureBodies(BodyCapture{DebugHeader: "X-Debug-Body"})
+	})
+	assert.NotPanics(t, func() {
+		CaptureBodies(BodyCapture{DebugHeader: "X-Debug-Body", DebugSecret: "s3cret"})
+	})
+}
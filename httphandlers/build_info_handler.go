@@ -0,0 +1,101 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// BuildInfoPath is the path FT services expose their build information on.
+const BuildInfoPath = "/__build-info"
+
+// BuildInfo is the version information reported by the build-info endpoint.
+type BuildInfo struct {
+	Module       string            `json:"module,omitempty"`
+	Version      string            `json:"version,omitempty"`
+	Revision     string            `json:"revision,omitempty"`
+	CommitTime   string            `json:"commitTime,omitempty"`
+	Dirty        bool              `json:"dirty"`
+	GoVersion    string            `json:"goVersion,omitempty"`
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+}
+
+// BuildInfoHandler creates new http.Handler reporting the build information of the running binary in JSON.
+// The information is read with debug.ReadBuildInfo. Non-empty fields of injected, typically set with ldflags
+// at build time, take precedence over the values read from the binary.
+func BuildInfoHandler(injected BuildInfo) http.Handler {
+	var info BuildInfo
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info = buildInfoFrom(bi)
+	}
+	info = mergeBuildInfo(info, injected)
+
+	body, err := json.Marshal(info)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err != nil {
+			http.Error(w, "failed to encode build info", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+}
+
+func buildInfoFrom(bi *debug.BuildInfo) BuildInfo {
+	info := BuildInfo{
+		Module:    bi.Main.Path,
+		Version:   bi.Main.Version,
+		GoVersion: bi.GoVersion,
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.CommitTime = s.Value
+		case "vcs.modified":
+			info.Dirty = s.Value == "true"
+		}
+	}
+	if len(bi.Deps) > 0 {
+		info.Dependencies = make(map[string]string, len(bi.Deps))
+		for _, dep := range bi.Deps {
+			version := dep.Version
+			if r := dep.Replace; r != nil {
+				// replaced dependencies are reported as their replacement, local replacements have no version
+				version = strings.TrimSpace(r.Path + " " + r.Version)
+			}
+			info.Dependencies[dep.Path] = version
+		}
+	}
+	return info
+}
+
+func mergeBuildInfo(info, injected BuildInfo) BuildInfo {
+	if injected.Module != "" {
+		info.Module = injected.Module
+	}
+	if injected.Version != "" {
+		info.Version = injected.Version
+	}
+	if injected.Revision != "" {
+		info.Revision = injected.Revision
+	}
+	if injected.CommitTime != "" {
+		info.CommitTime = injected.CommitTime
+	}
+	if injected.Dirty {
+		info.Dirty = true
+	}
+	if injected.GoVersion != "" {
+		info.GoVersion = injected.GoVersion
+	}
+	for path, version := range injected.Dependencies {
+		if info.Dependencies == nil {
+			info.Dependencies = map[string]string{}
+		}
+		info.Dependencies[path] = version
+	}
+	return info
+}
@@ -0,0 +1,87 @@
+package httphandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildInfoHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	resp := httptest.NewRecorder()
+	BuildInfoHandler(BuildInfo{Version: "v1.2.3", Revision: "abc123"}).ServeHTTP(resp, httptest.NewRequest("GET", BuildInfoPath, nil))
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("application/json", resp.Header().Get("Content-Type"))
+
+	var info BuildInfo
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &info))
+	assert.Equal("v1.2.3", info.Version)
+	assert.Equal("abc123", info.Revision)
+	assert.Equal(runtime.Version(), info.GoVersion)
+}
+
+func TestBuildInfoFrom(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *debug.BuildInfo
+		injected BuildInfo
+		expected BuildInfo
+	}{
+		{
+			name: "vcs settings and dependencies",
+			input: &debug.BuildInfo{
+				GoVersion: "go1.25.0",
+				Main:      debug.Module{Path: "github.com/Financial-Times/test-service", Version: "v1.0.0"},
+				Deps: []*debug.Module{
+					{Path: "github.com/Financial-Times/go-logger/v2", Version: "v2.0.1"},
+					{Path: "github.com/rcrowley/go-metrics", Version: "v0.0.1", Replace: &debug.Module{Path: "github.com/fork/go-metrics", Version: "v0.0.2"}},
+					{Path: "github.com/Financial-Times/transactionid-utils-go", Version: "v1.0.0", Replace: &debug.Module{Path: "../transactionid-utils-go"}},
+				},
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "8a3dc46"},
+					{Key: "vcs.time", Value: "2024-01-02T03:04:05Z"},
+					{Key: "vcs.modified", Value: "true"},
+				},
+			},
+			expected: BuildInfo{
+				Module:     "github.com/Financial-Times/test-service",
+				Version:    "v1.0.0",
+				Revision:   "8a3dc46",
+				CommitTime: "2024-01-02T03:04:05Z",
+				Dirty:      true,
+				GoVersion:  "go1.25.0",
+				Dependencies: map[string]string{
+					"github.com/Financial-Times/go-logger/v2":           "v2.0.1",
+					"github.com/rcrowley/go-metrics":                    "github.com/fork/go-metrics v0.0.2",
+					"github.com/Financial-Times/transactionid-utils-go": "../transactionid-utils-go",
+				},
+			},
+		},
+		{
+			name: "ldflags values take precedence",
+			input: &debug.BuildInfo{
+				GoVersion: "go1.25.0",
+				Main:      debug.Module{Path: "github.com/Financial-Times/test-service", Version: "(devel)"},
+			},
+			injected: BuildInfo{Version: "v2.0.0", Revision: "deadbeef"},
+			expected: BuildInfo{
+				Module:    "github.com/Financial-Times/test-service",
+				Version:   "v2.0.0",
+				Revision:  "deadbeef",
+				GoVersion: "go1.25.0",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := mergeBuildInfo(buildInfoFrom(test.input), test.injected)
+			assert.Equal(t, test.expected, res)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package httphandlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+)
+
+// ErrCircuitOpen is returned by the circuit breaker RoundTripper when the circuit for the requested host is open
+// and the request was rejected without being sent.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of the circuit breaker for a single downstream host.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through and counts failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests until the open timeout has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through to decide whether to close the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitFailureFunc decides whether the outcome of a round trip counts as a failure for the circuit breaker.
+type CircuitFailureFunc func(resp *http.Response, err error) bool
+
+type circuitBreakerOpt func(cb *circuitBreakerRoundTripper)
+
+// CircuitBreakerFailureRate opens the circuit when at least minRequests requests were made in the current window
+// and the ratio of failed requests reaches threshold (a value between 0 and 1).
+func CircuitBreakerFailureRate(threshold float64, minRequests int) circuitBreakerOpt { // nolint:golint // we don't want circuitBreakerOpt exported
+	return func(cb *circuitBreakerRoundTripper) {
+		cb.failureRate = threshold
+		cb.minRequests = minRequests
+	}
+}
+
+// CircuitBreakerConsecutiveFailures opens the circuit after n consecutive failed requests.
+// Zero disables the check.
+func CircuitBreakerConsecutiveFailures(n int) circuitBreakerOpt { // nolint:golint // we don't want circuitBreakerOpt exported
+	return func(cb *circuitBreakerRoundTripper) {
+		cb.consecutiveFailures = n
+	}
+}
+
+// CircuitBreakerWindow sets the length of the window over which the failure rate is calculated.
+func CircuitBreakerWindow(d time.Duration) circuitBreakerOpt { // nolint:golint // we don't want circuitBreakerOpt exported
+	return func(cb *circuitBreakerRoundTripper) {
+		cb.window = d
+	}
+}
+
+// CircuitBreakerOpenTimeout sets how long the circuit stays open before probe requests are let through.
+func CircuitBreakerOpenTimeout(d time.Duration) circuitBreakerOpt { // nolint:golint // we don't want circuitBreakerOpt exported
+	return func(cb *circuitBreakerRoundTripper) {
+		cb.openTimeout = d
+	}
+}
+
+// CircuitBreakerHalfOpenRequests sets how many probe requests are allowed while the circuit is half-open.
+// The circuit closes once that many probes succeeded.
+func CircuitBreakerHalfOpenRequests(n int) circuitBreakerOpt { // nolint:golint // we don't want circuitBreakerOpt exported
+	return func(cb *circuitBreakerRoundTripper) {
+		cb.halfOpenRequests = n
+	}
+}
+
+// CircuitBreakerFailureFunc replaces the default failure classification.
+// By default transport errors and 5xx responses are failures.
+func CircuitBreakerFailureFunc(fn CircuitFailureFunc) circuitBreakerOpt { // nolint:golint // we don't want circuitBreakerOpt exported
+	return func(cb *circuitBreakerRoundTripper) {
+		cb.isFailure = fn
+	}
+}
+
+// CircuitBreakerRoundTripper creates new http.RoundTripper that keeps a circuit breaker per downstream host.
+// Requests to a host whose circuit is open fail fast with an error wrapping ErrCircuitOpen.
+// State changes are logged and the current state of every host is exposed as a gauge in the registry.
+// If next is nil http.DefaultTransport is used.
+func CircuitBreakerRoundTripper(log *logger.UPPLogger, registry metrics.Registry, next http.RoundTripper, options ...circuitBreakerOpt) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	cb := &circuitBreakerRoundTripper{
+		logger:              log,
+		registry:            registry,
+		next:                next,
+		failureRate:         0.5,
+		minRequests:         20,
+		consecutiveFailures: 5,
+		window:              time.Minute,
+		openTimeout:         30 * time.Second,
+		halfOpenRequests:    1,
+		isFailure:           defaultCircuitFailure,
+		now:                 time.Now,
+		circuits:            map[string]*hostCircuit{},
+	}
+	for _, opt := range options {
+		opt(cb)
+	}
+	return cb
+}
+
+type circuitBreakerRoundTripper struct {
+	logger   *logger.UPPLogger
+	registry metrics.Registry
+	next     http.RoundTripper
+
+	failureRate         float64
+	minRequests         int
+	consecutiveFailures int
+	window              time.Duration
+	openTimeout         time.Duration
+	halfOpenRequests    int
+	isFailure           CircuitFailureFunc
+	now                 func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*hostCircuit
+}
+
+// hostCircuit holds the circuit breaker state of a single host.
+// All fields are guarded by the mutex of the owning circuitBreakerRoundTripper.
+type hostCircuit struct {
+	state       CircuitState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	openedAt    time.Time
+	probes      int
+	successes   int
+}
+
+func (cb *circuitBreakerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	generation, err := cb.allow(host)
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := cb.next.RoundTrip(req)
+	cb.record(host, generation, cb.isFailure(resp, err))
+	return resp, err
+}
+
+// allow checks whether a request to the host can be sent and returns the generation of the circuit it was admitted in.
+func (cb *circuitBreakerRoundTripper) allow(host string) (uint64, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(host)
+	now := cb.now()
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < cb.openTimeout {
+			metrics.GetOrRegisterCounter("circuit_breaker."+host+".rejected", cb.registry).Inc(1)
+			return 0, fmt.Errorf("%w for host %s", ErrCircuitOpen, host)
+		}
+		cb.setState(host, c, CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= cb.halfOpenRequests {
+			metrics.GetOrRegisterCounter("circuit_breaker."+host+".rejected", cb.registry).Inc(1)
+			return 0, fmt.Errorf("%w for host %s", ErrCircuitOpen, host)
+		}
+		c.probes++
+	default:
+		if now.Sub(c.windowStart) >= cb.window {
+			c.windowStart = now
+			c.requests = 0
+			c.failures = 0
+		}
+	}
+	return c.generation, nil
+}
+
+// record updates the host circuit with the outcome of a request.
+// Outcomes of requests admitted before the last state change are ignored.
+func (cb *circuitBreakerRoundTripper) record(host string, generation uint64, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	c := cb.circuit(host)
+	if c.generation != generation {
+		return
+	}
+
+	switch c.state {
+	case CircuitHalfOpen:
+		if failed {
+			cb.setState(host, c, CircuitOpen)
+			return
+		}
+		c.successes++
+		if c.successes >= cb.halfOpenRequests {
+			cb.setState(host, c, CircuitClosed)
+		}
+	case CircuitClosed:
+		c.requests++
+		if !failed {
+			c.consecutive = 0
+			return
+		}
+		c.failures++
+		c.consecutive++
+		if cb.consecutiveFailures > 0 && c.consecutive >= cb.consecutiveFailures {
+			cb.setState(host, c, CircuitOpen)
+			return
+		}
+		if cb.failureRate > 0 && c.requests >= cb.minRequests && float64(c.failures)/float64(c.requests) >= cb.failureRate {
+			cb.setState(host, c, CircuitOpen)
+		}
+	}
+}
+
+func (cb *circuitBreakerRoundTripper) circuit(host string) *hostCircuit {
+	c, ok := cb.circuits[host]
+	if !ok {
+		c = &hostCircuit{state: CircuitClosed, windowStart: cb.now()}
+		cb.circuits[host] = c
+		metrics.GetOrRegisterGauge("circuit_breaker."+host+".state", cb.registry).Update(int64(CircuitClosed))
+	}
+	return c
+}
+
+func (cb *circuitBreakerRoundTripper) setState(host string, c *hostCircuit, state CircuitState) {
+	from := c.state
+	now := cb.now()
+	*c = hostCircuit{state: state, generation: c.generation + 1, windowStart: now}
+	if state == CircuitOpen {
+		c.openedAt = now
+	}
+
+	metrics.GetOrRegisterGauge("circuit_breaker."+host+".state", cb.registry).Update(int64(state))
+	entry := cb.logger.WithFields(map[string]interface{}{
+		"host":       host,
+		"from_state": from.String(),
+		"to_state":   state.String(),
+	})
+	if state == CircuitOpen {
+		entry.Warn("circuit breaker state changed")
+		return
+	}
+	entry.Info("circuit breaker state changed")
+}
+
+func defaultCircuitFailure(resp *http.Response, err error) bool {
+	return err != nil || resp.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,124 @@
+package httphandlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestCircuitBreakerRoundTripper(t *testing.T) {
+	assert := assert.New(t)
+
+	r := metrics.NewRegistry()
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	status := http.StatusInternalServerError
+	calls := 0
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: status, Request: req}, nil
+	})
+
+	now := time.Now()
+	rt := CircuitBreakerRoundTripper(log, r, next,
+		CircuitBreakerConsecutiveFailures(3),
+		CircuitBreakerFailureRate(0, 0),
+		CircuitBreakerOpenTimeout(time.Minute),
+	)
+	rt.(*circuitBreakerRoundTripper).now = func() time.Time { return now }
+
+	roundTrip := func(url string) error {
+		req, err := http.NewRequest("GET", url, nil)
+		assert.NoError(err)
+		_, err = rt.RoundTrip(req)
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(roundTrip("http://failing.example.com/"))
+	}
+	state := metrics.GetOrRegisterGauge("circuit_breaker.failing.example.com.state", r)
+	assert.Equal(int64(CircuitOpen), state.Value(), "Circuit should be open after consecutive failures")
+
+	err := roundTrip("http://failing.example.com/")
+	assert.True(errors.Is(err, ErrCircuitOpen), "Request should fail fast while the circuit is open")
+	assert.Equal(3, calls, "Rejected request should not reach the downstream host")
+	assert.Equal(int64(1), metrics.GetOrRegisterCounter("circuit_breaker.failing.example.com.rejected", r).Count())
+
+	status = http.StatusOK
+	assert.NoError(roundTrip("http://other.example.com/"), "Other hosts should not be affected")
+
+	now = now.Add(time.Minute)
+	assert.NoError(roundTrip("http://failing.example.com/"), "Probe request should be allowed after the open timeout")
+	assert.Equal(int64(CircuitClosed), state.Value(), "Circuit should close after a successful probe")
+	assert.Contains(buf.String(), `"to_state":"open"`)
+	assert.Contains(buf.String(), `"to_state":"half-open"`)
+	assert.Contains(buf.String(), `"to_state":"closed"`)
+}
+
+func TestCircuitBreakerFailedProbeReopensCircuit(t *testing.T) {
+	assert := assert.New(t)
+
+	r := metrics.NewRegistry()
+	log := logger.NewUPPInfoLogger("test-service")
+	log.Out = new(bytes.Buffer)
+
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+
+	now := time.Now()
+	rt := CircuitBreakerRoundTripper(log, r, next, CircuitBreakerConsecutiveFailures(1), CircuitBreakerOpenTimeout(time.Second))
+	rt.(*circuitBreakerRoundTripper).now = func() time.Time { return now }
+
+	req, _ := http.NewRequest("GET", "http://down.example.com/", nil)
+	_, err := rt.RoundTrip(req)
+	assert.False(errors.Is(err, ErrCircuitOpen))
+
+	now = now.Add(time.Second)
+	_, err = rt.RoundTrip(req)
+	assert.False(errors.Is(err, ErrCircuitOpen), "Probe should reach the downstream host")
+
+	_, err = rt.RoundTrip(req)
+	assert.True(errors.Is(err, ErrCircuitOpen), "Failed probe should open the circuit again")
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	assert := assert.New(t)
+
+	r := metrics.NewRegistry()
+	log := logger.NewUPPInfoLogger("test-service")
+	log.Out = new(bytes.Buffer)
+
+	i := 0
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		i++
+		if i%2 == 0 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	rt := CircuitBreakerRoundTripper(log, r, next, CircuitBreakerConsecutiveFailures(0), CircuitBreakerFailureRate(0.5, 4))
+	req, _ := http.NewRequest("GET", "http://flaky.example.com/", nil)
+	for j := 0; j < 4; j++ {
+		_, err := rt.RoundTrip(req)
+		assert.NoError(err)
+	}
+	_, err := rt.RoundTrip(req)
+	assert.True(errors.Is(err, ErrCircuitOpen), "Circuit should open when the failure rate is reached")
+}
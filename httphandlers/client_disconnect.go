@@ -0,0 +1,45 @@
+package httphandlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// statusClientClosedRequest is the status logged for requests the client gave up on before the response was complete,
+// following the nginx convention
+const statusClientClosedRequest = 499
+
+// clientAborted reports whether the client closed the connection before the request was handled.
+// net/http cancels the request context without a cause as soon as it notices the connection is gone,
+// so a context canceled with any other cause, e.g. by a timeout middleware using context.WithCancelCause,
+// isn't a client abort. A context canceled without a cause by an outer handler can't be told apart from
+// a client disconnect and is reported as one.
+func clientAborted(req *http.Request) bool {
+	ctx := req.Context()
+	return errors.Is(ctx.Err(), context.Canceled) && context.Cause(ctx) == context.Canceled
+}
+
+// countAbortedRequest increments the aborted requests counter if a registry was provided
+func (h transactionAwareRequestLoggingHandler) countAbortedRequest() {
+	if h.registry != nil {
+		metrics.GetOrRegisterCounter("aborted_requests", h.registry).Inc(1)
+	}
+}
+
+// addDeliveryFields adds to fields what is known about the failure to deliver the response, if any.
+// The status the handler wrote before the client went away is kept as response_status.
+func addDeliveryFields(fields map[string]interface{}, aborted bool, w loggingResponseWriter) {
+	if aborted {
+		fields["client_aborted"] = true
+		if status := w.Status(); status != 0 {
+			fields["response_status"] = status
+		}
+	}
+	if err := w.writeError(); err != nil {
+		fields["write_error"] = err.Error()
+		fields["attempted_size"] = w.attemptedSize()
+	}
+}
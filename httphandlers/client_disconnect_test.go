@@ -0,0 +1,121 @@
+package httphandlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenPipeWriter accepts the first limit bytes and fails afterwards
+type brokenPipeWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (w *brokenPipeWriter) Write(b []byte) (int, error) {
+	if len(b) <= w.limit {
+		w.limit -= len(b)
+		return w.ResponseRecorder.Write(b)
+	}
+	n, _ := w.ResponseRecorder.Write(b[:w.limit])
+	w.limit = 0
+	return n, errors.New("write: broken pipe")
+}
+
+func TestClientDisconnect(t *testing.T) {
+	tests := []struct {
+		name                 string
+		cancel               bool
+		cause                error
+		limit                int
+		expectedStatus       int
+		expectedAborted      bool
+		expectedWriteError   bool
+		expectedSize         int
+		expectedAbortedCount int64
+	}{
+		{
+			name:           "delivered",
+			limit:          100,
+			expectedStatus: http.StatusOK,
+			expectedSize:   11,
+		},
+		{
+			name:                 "client gone",
+			cancel:               true,
+			limit:                4,
+			expectedStatus:       statusClientClosedRequest,
+			expectedAborted:      true,
+			expectedWriteError:   true,
+			expectedSize:         4,
+			expectedAbortedCount: 1,
+		},
+		{
+			name:           "canceled with a cause",
+			cancel:         true,
+			cause:          errors.New("upstream timeout"),
+			limit:          100,
+			expectedStatus: http.StatusOK,
+			expectedSize:   11,
+		},
+		{
+			name:               "write error only",
+			limit:              4,
+			expectedStatus:     http.StatusOK,
+			expectedWriteError: true,
+			expectedSize:       4,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+			registry := metrics.NewRegistry()
+
+			ctx, cancel := context.WithCancelCause(context.Background())
+			defer cancel(nil)
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				if test.cancel {
+					cancel(test.cause)
+				}
+				_, _ = w.Write([]byte("first"))
+				_, _ = w.Write([]byte("second"))
+			})
+			req := httptest.NewRequest("GET", "/content", nil).WithContext(ctx)
+			w := &brokenPipeWriter{ResponseRecorder: httptest.NewRecorder(), limit: test.limit}
+			TransactionAwareRequestLoggingHandler(log, inner, RecordMetrics(registry)).ServeHTTP(w, req)
+
+			entries := logEntries(t, buf)
+			assert.Len(entries, 1)
+			entry := entries[0]
+			assert.Equal(float64(test.expectedStatus), entry["status"])
+			assert.Equal(float64(test.expectedSize), entry["size"])
+			if test.expectedAborted {
+				assert.Equal(true, entry["client_aborted"])
+				assert.Equal(float64(http.StatusOK), entry["response_status"])
+			} else {
+				assert.NotContains(entry, "client_aborted")
+				assert.NotContains(entry, "response_status")
+			}
+			if test.expectedWriteError {
+				assert.Equal("write: broken pipe", entry["write_error"], "The first write error should be logged")
+				assert.Equal(float64(11), entry["attempted_size"])
+			} else {
+				assert.NotContains(entry, "write_error")
+				assert.NotContains(entry, "attempted_size")
+			}
+			assert.Equal(test.expectedAbortedCount, metrics.GetOrRegisterCounter("aborted_requests", registry).Count())
+		})
+	}
+}
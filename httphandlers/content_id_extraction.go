@@ -0,0 +1,116 @@
+package httphandlers
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ContentIDExtractor returns the content IDs found in the logged URI of a request.
+// The URI has already been through the query redaction, if any.
+type ContentIDExtractor func(uri string) []string
+
+// ExtractContentIDs creates a handler option that replaces the extraction of the IDs logged in the uuid field.
+// By default all UUIDs found in the URI are logged. Duplicate IDs are always logged once.
+func ExtractContentIDs(fn ContentIDExtractor) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.contentIDFn = fn
+	}
+}
+
+// MaxLoggedContentIDs creates a handler option that limits how many content IDs are logged in the uuid field.
+func MaxLoggedContentIDs(n int) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.maxContentIDs = n
+	}
+}
+
+// URIUUIDs returns all UUIDs (v1 to v8) found in the URI. It is the default ContentIDExtractor.
+func URIUUIDs(uri string) []string {
+	return getUUIDsFromURI(uri)
+}
+
+// RegexpContentIDs creates a ContentIDExtractor returning all matches of re in the URI.
+func RegexpContentIDs(re *regexp.Regexp) ContentIDExtractor {
+	return func(uri string) []string {
+		return re.FindAllString(uri, -1)
+	}
+}
+
+// QueryParamContentIDs creates a ContentIDExtractor returning the values of the given query parameters.
+func QueryParamContentIDs(params ...string) ContentIDExtractor {
+	return func(uri string) []string {
+		_, rawQuery, ok := strings.Cut(uri, "?")
+		if !ok {
+			return nil
+		}
+		query, _ := url.ParseQuery(rawQuery)
+		var ids []string
+		for _, p := range params {
+			for _, v := range query[p] {
+				if v != "" {
+					ids = append(ids, v)
+				}
+			}
+		}
+		return ids
+	}
+}
+
+// PathSegmentContentIDs creates a ContentIDExtractor returning the path segments at the given positions.
+// Positions start at 0 for the first non-empty segment, negative positions count from the last segment,
+// e.g. -1 for /content/{id}.
+func PathSegmentContentIDs(positions ...int) ContentIDExtractor {
+	return func(uri string) []string {
+		path, _, _ := strings.Cut(uri, "?")
+		var segments []string
+		for _, s := range strings.Split(path, "/") {
+			if s != "" {
+				segments = append(segments, s)
+			}
+		}
+
+		var ids []string
+		for _, p := range positions {
+			if p < 0 {
+				p += len(segments)
+			}
+			if p >= 0 && p < len(segments) {
+				ids = append(ids, segments[p])
+			}
+		}
+		return ids
+	}
+}
+
+// CombineContentIDExtractors creates a ContentIDExtractor returning the IDs found by all extractors in order.
+func CombineContentIDExtractors(extractors ...ContentIDExtractor) ContentIDExtractor {
+	return func(uri string) []string {
+		var ids []string
+		for _, fn := range extractors {
+			ids = append(ids, fn(uri)...)
+		}
+		return ids
+	}
+}
+
+// contentIDs extracts the deduplicated content IDs of the uri, limited to the configured maximum
+func (h transactionAwareRequestLoggingHandler) contentIDs(uri string) []string {
+	if h.contentIDFn == nil {
+		return nil
+	}
+	found := h.contentIDFn(uri)
+	seen := make(map[string]bool, len(found))
+	var ids []string
+	for _, id := range found {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		if h.maxContentIDs > 0 && len(ids) == h.maxContentIDs {
+			break
+		}
+	}
+	return ids
+}
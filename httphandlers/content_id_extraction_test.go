@@ -0,0 +1,113 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentIDExtractors(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor ContentIDExtractor
+		input     string
+		expected  []string
+	}{
+		{
+			name:      "default",
+			extractor: URIUUIDs,
+			input:     "/content/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe?id=87645070-7d8a-492e-9695-bf61ac2b4d18",
+			expected:  []string{"0c2c70cc-b801-11e8-bbc3-ccd7de085ffe", "87645070-7d8a-492e-9695-bf61ac2b4d18"},
+		},
+		{
+			name:      "custom regexp",
+			extractor: RegexpContentIDs(regexp.MustCompile(`\bFT-\d+\b`)),
+			input:     "/archive/FT-123/related/FT-456",
+			expected:  []string{"FT-123", "FT-456"},
+		},
+		{
+			name:      "query parameters",
+			extractor: QueryParamContentIDs("contentId", "listId"),
+			input:     "/notifications?contentId=abc&page=2&listId=def&contentId=ghi",
+			expected:  []string{"abc", "ghi", "def"},
+		},
+		{
+			name:      "query parameters without query",
+			extractor: QueryParamContentIDs("contentId"),
+			input:     "/notifications",
+			expected:  nil,
+		},
+		{
+			name:      "path segments",
+			extractor: PathSegmentContentIDs(1, -1),
+			input:     "/content/abc/annotations/def?bindings=v2",
+			expected:  []string{"abc", "def"},
+		},
+		{
+			name:      "path segments out of range",
+			extractor: PathSegmentContentIDs(5, -5),
+			input:     "/content/abc",
+			expected:  nil,
+		},
+		{
+			name:      "combined",
+			extractor: CombineContentIDExtractors(PathSegmentContentIDs(-1), QueryParamContentIDs("id")),
+			input:     "/lists/abc?id=def",
+			expected:  []string{"abc", "def"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.extractor(test.input))
+		})
+	}
+}
+
+func TestLoggedContentIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		options  []handlerOpt
+		expected interface{}
+	}{
+		{
+			name:     "deduplicated",
+			url:      "/content/abc/related/abc/def",
+			options:  []handlerOpt{ExtractContentIDs(PathSegmentContentIDs(1, 3, 4))},
+			expected: "abc,def",
+		},
+		{
+			name: "capped",
+			url:  "/content/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe/87645070-7d8a-492e-9695-bf61ac2b4d18/4d9ebbdc-03a1-11e9-9d01-cd4d49afbbe3",
+			options: []handlerOpt{
+				MaxLoggedContentIDs(2),
+			},
+			expected: "0c2c70cc-b801-11e8-bbc3-ccd7de085ffe,87645070-7d8a-492e-9695-bf61ac2b4d18",
+		},
+		{
+			name:     "disabled",
+			url:      "/content/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe",
+			options:  []handlerOpt{ExtractContentIDs(nil)},
+			expected: nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK}, test.options...)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.url, nil))
+
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			assert.Equal(t, test.expected, entries[0]["uuid"])
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package httphandlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is the configuration of CORSHandler.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed exactly, e.g. https://www.ft.com, with a wildcard subdomain,
+	// e.g. https://*.ft.com, or with a wildcard port, e.g. http://localhost:*. "*" allows all origins.
+	// A wildcard subdomain matches one or more whole labels, a wildcard port matches digits only.
+	AllowedOrigins []string
+	// AllowedOriginPatterns are regular expressions matching the allowed origins.
+	AllowedOriginPatterns []*regexp.Regexp
+	// AllowOrigin is called for the origins not allowed by AllowedOrigins or AllowedOriginPatterns.
+	AllowOrigin func(origin string, req *http.Request) bool
+	// AllowedMethods are the methods allowed in preflight requests, GET, HEAD and POST if not set.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in preflight requests. "*" allows all headers.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers the browser makes available to the scripts.
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers. It can't be combined with the "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long the browsers can cache the result of preflight requests, not sent if it is 0.
+	MaxAge time.Duration
+}
+
+// CORSHandler handles the Cross-Origin Resource Sharing headers of the requests.
+// Preflight requests are answered with 204 No Content without calling the inner handler. The CORS headers are
+// left out of the responses to the requests from disallowed origins, so the browsers block them.
+// It panics if credentials are allowed for all origins, as it would let any site make credentialed requests,
+// and if an allowed origin has a wildcard anywhere else than as the leading label of the host or as the port.
+func CORSHandler(config CORSConfig, h http.Handler) http.Handler {
+	handler := corsHandler{
+		handler:          h,
+		originPatterns:   config.AllowedOriginPatterns,
+		allowOrigin:      config.AllowOrigin,
+		allowedMethods:   map[string]bool{},
+		allowedHeaders:   map[string]bool{},
+		exposedHeaders:   strings.Join(config.ExposedHeaders, ", "),
+		allowCredentials: config.AllowCredentials,
+	}
+	for _, origin := range config.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			handler.allowAllOrigins = true
+			continue
+		}
+		if strings.Contains(origin, "*") {
+			handler.wildcardOrigins = append(handler.wildcardOrigins, parseWildcardOrigin(origin))
+			continue
+		}
+		if handler.origins == nil {
+			handler.origins = map[string]bool{}
+		}
+		handler.origins[origin] = true
+	}
+	if handler.allowAllOrigins && handler.allowCredentials {
+		panic(`httphandlers: CORSHandler can't allow credentials for the "*" origin`)
+	}
+
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	for _, m := range methods {
+		handler.allowedMethods[strings.ToUpper(m)] = true
+	}
+	handler.methods = strings.ToUpper(strings.Join(methods, ", "))
+
+	for _, header := range config.AllowedHeaders {
+		if header == "*" {
+			handler.allowAllHeaders = true
+			continue
+		}
+		handler.allowedHeaders[strings.ToLower(header)] = true
+	}
+	if config.MaxAge > 0 {
+		handler.maxAge = strconv.FormatInt(int64(config.MaxAge.Seconds()), 10)
+	}
+	return handler
+}
+
+// wildcardOrigin is an allowed origin with a wildcard subdomain, e.g. https://*.ft.com,
+// or a wildcard port, e.g. http://localhost:*
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+	port   bool
+}
+
+// parseWildcardOrigin parses an allowed origin with a wildcard, it panics if the wildcard is neither
+// the leading label of the host nor the port
+func parseWildcardOrigin(origin string) wildcardOrigin {
+	scheme, host, ok := strings.Cut(origin, "://")
+	if ok && scheme != "" && !strings.Contains(scheme, "*") && strings.Count(host, "*") == 1 {
+		if suffix := strings.TrimPrefix(host, "*"); len(suffix) > 1 && suffix[0] == '.' && suffix[1] != '.' {
+			return wildcardOrigin{prefix: scheme + "://", suffix: suffix}
+		}
+		if name := strings.TrimSuffix(host, ":*"); name != host && name != "" && !strings.ContainsAny(name, "*/") {
+			return wildcardOrigin{prefix: scheme + "://" + name + ":", port: true}
+		}
+	}
+	panic("httphandlers: CORSHandler only allows wildcards as the leading label of the host or as the port of an origin, not in " + origin)
+}
+
+// match reports whether origin is a subdomain or a port matching the wildcard
+func (w wildcardOrigin) match(origin string) bool {
+	if len(origin) <= len(w.prefix)+len(w.suffix) || !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+		return false
+	}
+	matched := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+	if w.port {
+		return isDigits(matched)
+	}
+	for _, label := range strings.Split(matched, ".") {
+		if label == "" || strings.Trim(label, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits reports whether s is made of ASCII digits only
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+type corsHandler struct {
+	handler          http.Handler
+	allowAllOrigins  bool
+	origins          map[string]bool
+	wildcardOrigins  []wildcardOrigin
+	originPatterns   []*regexp.Regexp
+	allowOrigin      func(origin string, req *http.Request) bool
+	allowedMethods   map[string]bool
+	methods          string
+	allowAllHeaders  bool
+	allowedHeaders   map[string]bool
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// isPreflight reports whether req is a CORS preflight request
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func (h corsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if isPreflight(req) {
+		h.handlePreflight(w, req)
+		return
+	}
+
+	header := w.Header()
+	if h.variesByOrigin() {
+		header.Add("Vary", "Origin")
+	}
+	origin := req.Header.Get("Origin")
+	if origin != "" && h.originAllowed(origin, req) {
+		h.setAllowOrigin(header, origin)
+		if h.exposedHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", h.exposedHeaders)
+		}
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+func (h corsHandler) handlePreflight(w http.ResponseWriter, req *http.Request) {
+	header := w.Header()
+	if h.variesByOrigin() {
+		header.Add("Vary", "Origin")
+	}
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	origin := req.Header.Get("Origin")
+	method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	requestedHeaders := parseHeaderList(req.Header.Values("Access-Control-Request-Headers"))
+	if !h.originAllowed(origin, req) || !h.allowedMethods[method] || !h.headersAllowed(requestedHeaders) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.setAllowOrigin(header, origin)
+	header.Set("Access-Control-Allow-Methods", h.methods)
+	if len(requestedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+	if h.maxAge != "" {
+		header.Set("Access-Control-Max-Age", h.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// variesByOrigin reports whether the CORS headers depend on the origin of the request,
+// in which case the responses have to be cached per origin
+func (h corsHandler) variesByOrigin() bool {
+	return !h.allowAllOrigins
+}
+
+func (h corsHandler) setAllowOrigin(header http.Header, origin string) {
+	if h.allowAllOrigins {
+		header.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	header.Set("Access-Control-Allow-Origin", origin)
+	if h.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (h corsHandler) originAllowed(origin string, req *http.Request) bool {
+	if h.allowAllOrigins {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if h.origins[lower] {
+		return true
+	}
+	for _, w := range h.wildcardOrigins {
+		if w.match(lower) {
+			return true
+		}
+	}
+	for _, re := range h.originPatterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return h.allowOrigin != nil && h.allowOrigin(origin, req)
+}
+
+func (h corsHandler) headersAllowed(headers []string) bool {
+	if h.allowAllHeaders {
+		return true
+	}
+	for _, header := range headers {
+		if !h.allowedHeaders[header] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHeaderList returns the lower cased header names of the Access-Control-Request-Headers values
+func parseHeaderList(values []string) []string {
+	var headers []string
+	for _, v := range values {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				headers = append(headers, name)
+			}
+		}
+	}
+	return headers
+}
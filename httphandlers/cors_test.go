@@ -0,0 +1,283 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSHandler(t *testing.T) {
+	config := CORSConfig{
+		AllowedOrigins:        []string{"https://www.ft.com", "https://*.ft.technology"},
+		AllowedOriginPatterns: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+		AllowOrigin: func(origin string, _ *http.Request) bool {
+			return origin == "https://partner.example.com"
+		},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "X-Api-Key"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	tests := []struct {
+		name            string
+		method          string
+		headers         map[string]string
+		expectedStatus  int
+		expectedInner   bool
+		expectedHeaders map[string]string
+		expectedVary    []string
+	}{
+		{
+			name:           "no origin",
+			method:         "GET",
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "exact origin",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "https://www.ft.com"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://www.ft.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Request-Id",
+				"Access-Control-Max-Age":           "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "wildcard subdomain",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "https://api.upp.ft.technology"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://api.upp.ft.technology",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "wildcard does not match the domain itself",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "https://ft.technology"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "wildcard suffix attack",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "https://evil.com/.ft.technology"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "regexp origin",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "http://localhost:8080"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "http://localhost:8080",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "callback origin",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "https://partner.example.com"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://partner.example.com",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:           "disallowed origin",
+			method:         "GET",
+			headers:        map[string]string{"Origin": "https://evil.com"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "",
+				"Access-Control-Expose-Headers": "",
+			},
+			expectedVary: []string{"Origin"},
+		},
+		{
+			name:   "preflight",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://www.ft.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "content-type, X-API-Key",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://www.ft.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "GET, PUT",
+				"Access-Control-Allow-Headers":     "content-type, x-api-key",
+				"Access-Control-Max-Age":           "600",
+			},
+			expectedVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:   "preflight with disallowed method",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                        "https://www.ft.com",
+				"Access-Control-Request-Method": "DELETE",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+			expectedVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:   "preflight with disallowed header",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://www.ft.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Authorization",
+			},
+			expectedStatus: http.StatusNoContent,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "",
+			},
+			expectedVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		{
+			name:           "options without preflight headers",
+			method:         "OPTIONS",
+			headers:        map[string]string{"Origin": "https://www.ft.com"},
+			expectedStatus: http.StatusOK,
+			expectedInner:  true,
+			expectedHeaders: map[string]string{
+				"Access-Control-Allow-Origin": "https://www.ft.com",
+			},
+			expectedVary: []string{"Origin"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			innerCalled := false
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				innerCalled = true
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest(test.method, "/content", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			CORSHandler(config, inner).ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatus, w.Code)
+			assert.Equal(test.expectedInner, innerCalled)
+			for key, value := range test.expectedHeaders {
+				assert.Equal(value, w.Header().Get(key), key)
+			}
+			assert.Equal(test.expectedVary, w.Header().Values("Vary"))
+		})
+	}
+}
+
+func TestCORSHandlerAllOrigins(t *testing.T) {
+	assert := assert.New(t)
+
+	config := CORSConfig{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}}
+	req := httptest.NewRequest("OPTIONS", "/content", nil)
+	req.Header.Set("Origin", "https://www.ft.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "X-Anything")
+	w := httptest.NewRecorder()
+	CORSHandler(config, innerHandler{Status: http.StatusOK}).ServeHTTP(w, req)
+
+	assert.Equal("*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal("x-anything", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal("GET, HEAD, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal([]string{"Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+}
+
+func TestCORSHandlerRefusesCredentialsForAllOrigins(t *testing.T) {
+	config := CORSConfig{AllowedOrigins: []string{"https://www.ft.com", "*"}, AllowCredentials: true}
+	assert.Panics(t, func() {
+		CORSHandler(config, innerHandler{Status: http.StatusOK})
+	})
+}
+
+func TestWildcardOrigins(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  string
+		origin   string
+		expected bool
+	}{
+		{name: "subdomain", allowed: "https://*.ft.com", origin: "https://www.ft.com", expected: true},
+		{name: "nested subdomain", allowed: "https://*.ft.com", origin: "https://api.upp.ft.com", expected: true},
+		{name: "subdomain with port", allowed: "https://*.ft.com:8443", origin: "https://www.ft.com:8443", expected: true},
+		{name: "domain itself", allowed: "https://*.ft.com", origin: "https://ft.com"},
+		{name: "label prefix", allowed: "https://*.ft.com", origin: "https://evilft.com"},
+		{name: "empty label", allowed: "https://*.ft.com", origin: "https://..ft.com"},
+		{name: "userinfo", allowed: "https://*.ft.com", origin: "https://evil.com@www.ft.com"},
+		{name: "other scheme", allowed: "https://*.ft.com", origin: "http://www.ft.com"},
+		{name: "port", allowed: "http://localhost:*", origin: "http://localhost:3000", expected: true},
+		{name: "no port", allowed: "http://localhost:*", origin: "http://localhost"},
+		{name: "port followed by a domain", allowed: "http://localhost:*", origin: "http://localhost:3000.evil.com"},
+		{name: "port followed by a path", allowed: "http://localhost:*", origin: "http://localhost:3000/evil"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, parseWildcardOrigin(test.allowed).match(test.origin))
+		})
+	}
+}
+
+func TestCORSHandlerRefusesMisplacedWildcards(t *testing.T) {
+	for _, origin := range []string{
+		"https://*ft.com",
+		"https://ft.*.com",
+		"https://www.ft.*",
+		"https://*.*.ft.com",
+		"https://*.",
+		"https://*..ft.com",
+		"*.ft.com",
+		"*://www.ft.com",
+		"https://www.ft.com:8*",
+		"https://*.ft.com:*",
+		"https://:*",
+	} {
+		t.Run(origin, func(t *testing.T) {
+			config := CORSConfig{AllowedOrigins: []string{origin}}
+			assert.Panics(t, func() {
+				CORSHandler(config, innerHandler{Status: http.StatusOK})
+			})
+		})
+	}
+}
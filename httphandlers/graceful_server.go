@@ -0,0 +1,195 @@
+package httphandlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+type gracefulServerOpt func(s *GracefulServer)
+
+// DrainPeriod sets how long the server keeps serving requests after the shutdown signal while __gtg reports unhealthy,
+// giving load balancers time to stop routing traffic to the instance.
+func DrainPeriod(d time.Duration) gracefulServerOpt { // nolint:golint // we don't want gracefulServerOpt exported
+	return func(s *GracefulServer) {
+		s.drainPeriod = d
+	}
+}
+
+// ShutdownTimeout sets how long the server waits for in-flight requests once it started rejecting new ones.
+func ShutdownTimeout(d time.Duration) gracefulServerOpt { // nolint:golint // we don't want gracefulServerOpt exported
+	return func(s *GracefulServer) {
+		s.shutdownTimeout = d
+	}
+}
+
+// ShutdownHealthService sets the HealthService which is marked as draining when the shutdown starts.
+func ShutdownHealthService(health *HealthService) gracefulServerOpt { // nolint:golint // we don't want gracefulServerOpt exported
+	return func(s *GracefulServer) {
+		s.health = health
+	}
+}
+
+// ShutdownSignals replaces the signals starting the graceful shutdown. By default these are SIGTERM and SIGINT.
+func ShutdownSignals(signals ...os.Signal) gracefulServerOpt { // nolint:golint // we don't want gracefulServerOpt exported
+	return func(s *GracefulServer) {
+		s.signals = signals
+	}
+}
+
+// GracefulServer wraps http.Server draining connections on shutdown.
+// When a shutdown signal is received the server marks __gtg as unhealthy, keeps serving for the drain period,
+// then rejects new requests with 503 and waits for the in-flight ones before shutting down the http.Server.
+type GracefulServer struct {
+	logger          *logger.UPPLogger
+	server          *http.Server
+	health          *HealthService
+	drainPeriod     time.Duration
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+
+	inFlight  int64
+	rejecting int32
+}
+
+// NewGracefulServer creates new GracefulServer for the provided http.Server.
+// The handler of the server, usually the chain of TransactionAwareRequestLoggingHandler and HTTPMetricsHandler,
+// is wrapped to track the in-flight requests.
+func NewGracefulServer(log *logger.UPPLogger, server *http.Server, options ...gracefulServerOpt) *GracefulServer {
+	s := &GracefulServer{
+		logger:          log,
+		server:          server,
+		drainPeriod:     10 * time.Second,
+		shutdownTimeout: 20 * time.Second,
+		signals:         []os.Signal{syscall.SIGTERM, os.Interrupt},
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	handler := server.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	server.Handler = s.trackInFlight(handler)
+	return s
+}
+
+// InFlight returns the number of requests currently being handled.
+func (s *GracefulServer) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// ListenAndServe listens on the address of the http.Server and serves requests until a shutdown signal is received
+// or ctx is done, then shuts down gracefully.
+func (s *GracefulServer) ListenAndServe(ctx context.Context) error {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves requests on the listener until a shutdown signal is received or parent is done, then shuts down gracefully.
+// Another shutdown signal during the drain period, or parent being done if the shutdown was started by a signal,
+// stops draining and starts rejecting new requests straight away.
+func (s *GracefulServer) Serve(parent context.Context, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(parent, s.signals...)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.server.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// a second signal, or the cancellation of parent if the shutdown was started by a signal, cuts the drain period short.
+	// The new signal context is created before stopping the first one, so the signals are never left unhandled.
+	drainParent := parent
+	if parent.Err() != nil {
+		drainParent = context.Background()
+	}
+	drainCtx, stopDrain := signal.NotifyContext(drainParent, s.signals...)
+	defer stopDrain()
+	stop()
+
+	s.logger.WithField("drain_period", s.drainPeriod.String()).Info("Shutdown started, draining connections")
+	if s.health != nil {
+		s.health.SetDraining(true)
+	}
+	drainTimer := time.NewTimer(s.drainPeriod)
+	select {
+	case <-drainTimer.C:
+	case <-drainCtx.Done():
+		drainTimer.Stop()
+		s.logger.Warn("Drain period interrupted")
+	}
+
+	atomic.StoreInt32(&s.rejecting, 1)
+	s.logger.WithField("in_flight", s.InFlight()).Info("Rejecting new requests, waiting for in-flight requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	if remaining := s.waitForInFlight(shutdownCtx); remaining > 0 {
+		s.logger.WithField("in_flight", remaining).Warn("Shutdown timeout reached with requests still running")
+	}
+
+	err := s.server.Shutdown(shutdownCtx)
+	if err != nil {
+		_ = s.server.Close()
+	}
+	if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	s.logger.Info("Shutdown completed")
+	return err
+}
+
+// waitForInFlight blocks until there are no in-flight requests or ctx is done, and returns the number of requests still running.
+func (s *GracefulServer) waitForInFlight(ctx context.Context) int64 {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		n := s.InFlight()
+		if n == 0 {
+			return 0
+		}
+		select {
+		case <-ctx.Done():
+			return n
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *GracefulServer) trackInFlight(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// the request is counted before checking the state so it can't slip through once we are waiting for in-flight requests
+		atomic.AddInt64(&s.inFlight, 1)
+		defer atomic.AddInt64(&s.inFlight, -1)
+
+		if atomic.LoadInt32(&s.rejecting) == 1 {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "service is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,180 @@
+package httphandlers
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGracefulServerDrainsConnections(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	health := NewHealthService("test-system", "Test Service", "", metrics.NewRegistry(), nil)
+	slowStarted := make(chan struct{})
+	releaseSlow := make(chan struct{})
+	var slowOnce sync.Once
+	mux := http.NewServeMux()
+	mux.Handle(GTGPath, health.GTGHandler())
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		slowOnce.Do(func() { close(slowStarted) })
+		<-releaseSlow
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+	url := "http://" + ln.Addr().String()
+
+	s := NewGracefulServer(log, &http.Server{Handler: mux},
+		DrainPeriod(100*time.Millisecond),
+		ShutdownTimeout(time.Second),
+		ShutdownHealthService(health),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	resp, err := client.Get(url + GTGPath)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	slowStatus := make(chan int, 1)
+	go func() {
+		resp, err := client.Get(url + "/slow")
+		if err != nil {
+			slowStatus <- 0
+			return
+		}
+		resp.Body.Close()
+		slowStatus <- resp.StatusCode
+	}()
+	<-slowStarted
+	assert.Equal(int64(1), s.InFlight())
+
+	cancel()
+	assert.Eventually(func() bool {
+		resp, err := client.Get(url + GTGPath)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 5*time.Millisecond, "__gtg should fail while draining")
+
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&s.rejecting) == 1
+	}, time.Second, 5*time.Millisecond, "The server should reject new requests after the drain period")
+	resp, err = client.Get(url + "/slow")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode, "New requests should be rejected after the drain period")
+	assert.True(resp.Close, "Rejected requests should close the connection")
+
+	close(releaseSlow)
+
+	assert.Equal(http.StatusOK, <-slowStatus, "In-flight request should complete")
+	assert.NoError(<-done)
+	assert.Contains(buf.String(), `"in_flight":1`)
+	assert.Contains(buf.String(), "Shutdown completed")
+}
+
+func TestGracefulServerDrainPeriodInterrupted(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("os.Interrupt can't be sent to a process on windows")
+	}
+	tests := []struct {
+		name      string
+		interrupt func(p *os.Process, cancel context.CancelFunc)
+	}{
+		{
+			name: "second signal",
+			interrupt: func(p *os.Process, _ context.CancelFunc) {
+				_ = p.Signal(os.Interrupt)
+			},
+		},
+		{
+			name: "parent context done",
+			interrupt: func(_ *os.Process, cancel context.CancelFunc) {
+				cancel()
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			health := NewHealthService("test-system", "Test Service", "", metrics.NewRegistry(), nil)
+			mux := http.NewServeMux()
+			mux.Handle(GTGPath, health.GTGHandler())
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if !assert.NoError(err) {
+				return
+			}
+			url := "http://" + ln.Addr().String()
+
+			s := NewGracefulServer(log, &http.Server{Handler: mux},
+				DrainPeriod(time.Minute),
+				ShutdownHealthService(health),
+				ShutdownSignals(os.Interrupt),
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- s.Serve(ctx, ln) }()
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+			gtgStatus := func() int {
+				resp, err := client.Get(url + GTGPath)
+				if err != nil {
+					return 0
+				}
+				resp.Body.Close()
+				return resp.StatusCode
+			}
+			assert.Eventually(func() bool { return gtgStatus() == http.StatusOK }, time.Second, 5*time.Millisecond)
+
+			p, err := os.FindProcess(os.Getpid())
+			if !assert.NoError(err) {
+				return
+			}
+			assert.NoError(p.Signal(os.Interrupt))
+			assert.Eventually(func() bool {
+				return gtgStatus() == http.StatusServiceUnavailable
+			}, time.Second, 5*time.Millisecond, "__gtg should fail while draining")
+
+			test.interrupt(p, cancel)
+			select {
+			case err := <-done:
+				assert.NoError(err)
+			case <-time.After(5 * time.Second):
+				assert.Fail("The drain period should have been interrupted")
+				return
+			}
+			assert.Contains(buf.String(), "Drain period interrupted")
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package httphandlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultHeaderDenyList returns the request headers which are not logged by default.
+// They are either logged in their own fields, sensitive or of no use for debugging.
+// A new slice is returned on every call so it can be modified safely.
+func DefaultHeaderDenyList() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile("(?i:^User-Agent$)"),
+		regexp.MustCompile("(?i:^Referer$)"),
+		regexp.MustCompile("(?i:^X-Request-Id$)"),
+		regexp.MustCompile("(?i:^X-Api-Key$)"),
+		regexp.MustCompile("(?i:^X-Varnish$)"),
+		regexp.MustCompile("(?i:^X-Timer$)"),
+		regexp.MustCompile("(?i:^Connection$)"),
+		regexp.MustCompile("(?i:^Content-Length$)"),
+		regexp.MustCompile("(?i:^Cdn-Loop$)"),
+		regexp.MustCompile("(?i:^Fastly)"),
+	}
+}
+
+// AllowHeaders creates a handler option that switches the request headers logging to allow-list mode.
+// Only the named headers are logged, provided they are not in the deny list.
+func AllowHeaders(names ...string) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.requestHeaders.allow = append(h.requestHeaders.allow, headerNamesRegexps(names)...)
+	}
+}
+
+// DenyHeaders creates a handler option that extends the deny list of the request headers of this handler.
+func DenyHeaders(patterns ...*regexp.Regexp) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.requestHeaders.deny = append(h.requestHeaders.deny, patterns...)
+	}
+}
+
+// ReplaceHeaderDenyList creates a handler option that replaces the deny list of the request headers of this handler.
+// Calling it without patterns removes the deny list entirely.
+func ReplaceHeaderDenyList(patterns ...*regexp.Regexp) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.requestHeaders.deny = patterns
+	}
+}
+
+// DenyResponseHeaders creates a handler option that extends the deny list of the response headers
+// and enables the response headers logging.
+func DenyResponseHeaders(patterns ...*regexp.Regexp) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		f := h.responseHeaderFilter()
+		f.deny = append(f.deny, patterns...)
+	}
+}
+
+// ReplaceResponseHeaderDenyList creates a handler option that replaces the deny list of the response headers
+// and enables the response headers logging.
+func ReplaceResponseHeaderDenyList(patterns ...*regexp.Regexp) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.responseHeaderFilter().deny = patterns
+	}
+}
+
+// headerFilter decides which headers are logged
+type headerFilter struct {
+	// deny are the headers that are never logged
+	deny []*regexp.Regexp
+	// allow, if not empty, are the only headers that are logged
+	allow []*regexp.Regexp
+	// fn is an additional filter executed for the headers that passed the lists
+	fn HeaderFilter
+}
+
+func (f headerFilter) allowed(key string) bool {
+	if len(f.allow) > 0 && !matchesAny(f.allow, key) {
+		return false
+	}
+	if matchesAny(f.deny, key) {
+		return false
+	}
+	if f.fn != nil {
+		return f.fn(key)
+	}
+	return true
+}
+
+func matchesAny(list []*regexp.Regexp, key string) bool {
+	for _, r := range list {
+		if r.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func getHeaders(header http.Header, filter headerFilter, redactor *headerRedactor) map[string]string {
+	headers := map[string]string{}
+	for key, val := range header {
+		if !filter.allowed(key) {
+			continue
+		}
+
+		if redactor != nil {
+			redacted := make([]string, len(val))
+			for i, v := range val {
+				redacted[i] = redactor.redact(key, v)
+			}
+			val = redacted
+		}
+		headers[key] = strings.Join(val, ", ")
+	}
+	return headers
+}
+
+// headerNamesRegexps creates case-insensitive regular expressions matching exactly the provided header names
+func headerNamesRegexps(names []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(names))
+	for i, name := range names {
+		res[i] = regexp.MustCompile("(?i:^" + regexp.QuoteMeta(name) + "$)")
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderLists(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []handlerOpt
+		expected interface{}
+	}{
+		{
+			name: "default deny list",
+			expected: map[string]interface{}{
+				"Accept":        "*/*",
+				"Cache-Control": "no-cache",
+				"X-Custom":      "custom",
+			},
+		},
+		{
+			name:    "allow list",
+			options: []handlerOpt{AllowHeaders("accept", "X-Api-Key")},
+			expected: map[string]interface{}{
+				"Accept": "*/*",
+			},
+		},
+		{
+			name:    "extended deny list",
+			options: []handlerOpt{DenyHeaders(regexp.MustCompile("(?i:^X-)"))},
+			expected: map[string]interface{}{
+				"Accept":        "*/*",
+				"Cache-Control": "no-cache",
+			},
+		},
+		{
+			name:    "replaced deny list",
+			options: []handlerOpt{ReplaceHeaderDenyList(regexp.MustCompile("(?i:^Accept$)"))},
+			expected: map[string]interface{}{
+				"Cache-Control": "no-cache",
+				"X-Custom":      "custom",
+				"X-Api-Key":     "secret",
+				"User-Agent":    "test agent",
+			},
+		},
+		{
+			name:    "allow list with replaced deny list",
+			options: []handlerOpt{ReplaceHeaderDenyList(), AllowHeaders("User-Agent")},
+			expected: map[string]interface{}{
+				"User-Agent": "test agent",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			req := httptest.NewRequest("GET", "/content", nil)
+			req.Header.Set("Accept", "*/*")
+			req.Header.Set("Cache-Control", "no-cache")
+			req.Header.Set("X-Custom", "custom")
+			req.Header.Set("X-Api-Key", "secret")
+			req.Header.Set("User-Agent", "test agent")
+			req.Header.Set("X-Request-Id", "tid_test")
+
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK}, test.options...)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			headers := entries[0]["headers"]
+			if m, ok := headers.(map[string]interface{}); ok {
+				// X-Request-Id is only denied by the default list
+				delete(m, "X-Request-Id")
+			}
+			assert.Equal(t, test.expected, headers)
+		})
+	}
+}
+
+func TestHeaderListsArePerHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	restricted := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK}, DenyHeaders(regexp.MustCompile("(?i:^Accept$)")))
+	standard := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK})
+
+	for _, handler := range []http.Handler{restricted, standard} {
+		req := httptest.NewRequest("GET", "/content", nil)
+		req.Header.Set("Accept", "*/*")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 2)
+	assert.NotContains(entries[0], "headers")
+	assert.Equal(map[string]interface{}{"Accept": "*/*"}, entries[1]["headers"])
+	assert.Len(DefaultHeaderDenyList(), 10, "The default deny list should not be modified")
+}
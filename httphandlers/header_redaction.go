@@ -0,0 +1,105 @@
+package httphandlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ValueMasker returns the replacement logged instead of a sensitive value.
+type ValueMasker func(value string) string
+
+// MaskWith creates a ValueMasker replacing every sensitive value with replacement.
+func MaskWith(replacement string) ValueMasker {
+	return func(string) string {
+		return replacement
+	}
+}
+
+// HashValues creates a ValueMasker replacing every sensitive value with a truncated SHA-256 hash,
+// so equal values can still be correlated across log entries.
+func HashValues() ValueMasker {
+	return func(value string) string {
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+}
+
+// HeaderRedaction configures the masking of header values in the request log.
+// The values of Authorization, Proxy-Authorization, Cookie and Set-Cookie headers are always redacted,
+// keeping the authorization scheme and the cookie names and attributes.
+type HeaderRedaction struct {
+	// Headers are the names of additional headers which values are masked entirely.
+	Headers []string
+	// Patterns are masked wherever they match in any logged header value, e.g. e-mail addresses or API keys.
+	Patterns []*regexp.Regexp
+	// Masker produces the replacement of a redacted value. Defaults to MaskWith("[REDACTED]").
+	Masker ValueMasker
+}
+
+// RedactHeaderValues creates a handler option that masks sensitive header values instead of dropping the headers,
+// so their presence is still visible in the logs.
+func RedactHeaderValues(r HeaderRedaction) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.headerRedactor = newHeaderRedactor(r)
+	}
+}
+
+type headerRedactor struct {
+	headers  map[string]bool
+	patterns []*regexp.Regexp
+	mask     ValueMasker
+}
+
+func newHeaderRedactor(r HeaderRedaction) *headerRedactor {
+	redactor := &headerRedactor{headers: map[string]bool{}, patterns: r.Patterns, mask: r.Masker}
+	if redactor.mask == nil {
+		redactor.mask = MaskWith("[REDACTED]")
+	}
+	for _, key := range r.Headers {
+		redactor.headers[http.CanonicalHeaderKey(key)] = true
+	}
+	return redactor
+}
+
+// redact returns the value of the header with the sensitive parts masked
+func (r *headerRedactor) redact(key, value string) string {
+	switch key = http.CanonicalHeaderKey(key); {
+	case key == "Authorization" || key == "Proxy-Authorization":
+		if scheme, credentials, ok := strings.Cut(value, " "); ok {
+			value = scheme + " " + r.mask(credentials)
+		} else {
+			value = r.mask(value)
+		}
+	case key == "Cookie":
+		cookies := strings.Split(value, ";")
+		for i, c := range cookies {
+			cookies[i] = r.redactCookie(c)
+		}
+		value = strings.Join(cookies, ";")
+	case key == "Set-Cookie":
+		cookie, attributes, ok := strings.Cut(value, ";")
+		value = r.redactCookie(cookie)
+		if ok {
+			value += ";" + attributes
+		}
+	case r.headers[key]:
+		value = r.mask(value)
+	}
+
+	for _, p := range r.patterns {
+		value = p.ReplaceAllStringFunc(value, r.mask)
+	}
+	return value
+}
+
+// redactCookie masks the value of a single name=value cookie pair
+func (r *headerRedactor) redactCookie(cookie string) string {
+	name, value, ok := strings.Cut(cookie, "=")
+	if !ok {
+		return r.mask(cookie)
+	}
+	return name + "=" + r.mask(value)
+}
@@ -0,0 +1,114 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderRedactor(t *testing.T) {
+	email := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+
+	tests := []struct {
+		name      string
+		redaction HeaderRedaction
+		key       string
+		value     string
+		expected  string
+	}{
+		{
+			name:     "authorization keeps the scheme",
+			key:      "Authorization",
+			value:    "Bearer eyJhbGciOiJIUzI1NiJ9.secret",
+			expected: "Bearer [REDACTED]",
+		},
+		{
+			name:     "authorization without scheme",
+			key:      "authorization",
+			value:    "secret",
+			expected: "[REDACTED]",
+		},
+		{
+			name:     "cookie keeps the names",
+			key:      "Cookie",
+			value:    "FTSession=abc; spoor-id=def",
+			expected: "FTSession=[REDACTED]; spoor-id=[REDACTED]",
+		},
+		{
+			name:     "set-cookie keeps the attributes",
+			key:      "Set-Cookie",
+			value:    "FTSession=abc; Path=/; Expires=Wed, 21 Oct 2015 07:28:00 GMT; HttpOnly",
+			expected: "FTSession=[REDACTED]; Path=/; Expires=Wed, 21 Oct 2015 07:28:00 GMT; HttpOnly",
+		},
+		{
+			name:      "configured header",
+			redaction: HeaderRedaction{Headers: []string{"x-session-token"}},
+			key:       "X-Session-Token",
+			value:     "secret",
+			expected:  "[REDACTED]",
+		},
+		{
+			name:     "not sensitive header",
+			key:      "Accept",
+			value:    "application/json",
+			expected: "application/json",
+		},
+		{
+			name:      "pattern",
+			redaction: HeaderRedaction{Patterns: []*regexp.Regexp{email}},
+			key:       "X-Forwarded-User",
+			value:     "user john.doe@ft.com via sso",
+			expected:  "user [REDACTED] via sso",
+		},
+		{
+			name:      "custom masker",
+			redaction: HeaderRedaction{Masker: MaskWith("***")},
+			key:       "Authorization",
+			value:     "Basic dXNlcjpwYXNz",
+			expected:  "Basic ***",
+		},
+		{
+			name:      "hashing masker",
+			redaction: HeaderRedaction{Masker: HashValues()},
+			key:       "Authorization",
+			value:     "Basic dXNlcjpwYXNz",
+			expected:  "Basic sha256:0e9d220616f08345",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := newHeaderRedactor(test.redaction).redact(test.key, test.value)
+			assert.Equal(t, test.expected, res)
+		})
+	}
+}
+
+func TestRedactHeaderValues(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	req := httptest.NewRequest("GET", "/content", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Add("Cookie", "a=1")
+	req.Header.Add("Cookie", "b=2")
+	req.Header.Set("Accept", "*/*")
+
+	handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK}, RedactHeaderValues(HeaderRedaction{}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal(map[string]interface{}{
+		"Authorization": "Bearer [REDACTED]",
+		"Cookie":        "a=[REDACTED], b=[REDACTED]",
+		"Accept":        "*/*",
+	}, entries[0]["headers"])
+}
@@ -0,0 +1,271 @@
+package httphandlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	// HealthPath is the path FT services expose their health check on.
+	HealthPath = "/__health"
+	// GTGPath is the path FT services expose their good-to-go check on.
+	GTGPath = "/__gtg"
+)
+
+// HealthCheck describes a single check reported by the health endpoint.
+// Severity follows the FT convention where 1 is the most severe.
+type HealthCheck struct {
+	ID               string
+	Name             string
+	Severity         uint8
+	BusinessImpact   string
+	TechnicalSummary string
+	PanicGuide       string
+	// Checker runs the check. The returned message is reported as the check output when the check passes.
+	Checker func(ctx context.Context) (string, error)
+}
+
+type healthOpt func(s *HealthService)
+
+// HealthCheckTimeout sets how long a single check may run before it is reported as failed.
+func HealthCheckTimeout(d time.Duration) healthOpt { // nolint:golint // we don't want healthOpt exported
+	return func(s *HealthService) {
+		s.timeout = d
+	}
+}
+
+// HealthCheckCacheDuration sets how long check results are reused before the checks are run again.
+func HealthCheckCacheDuration(d time.Duration) healthOpt { // nolint:golint // we don't want healthOpt exported
+	return func(s *HealthService) {
+		s.cacheDuration = d
+	}
+}
+
+// HealthService runs the health checks of a service and renders them on the __health and __gtg endpoints.
+type HealthService struct {
+	systemCode    string
+	name          string
+	description   string
+	checks        []HealthCheck
+	registry      metrics.Registry
+	timeout       time.Duration
+	cacheDuration time.Duration
+
+	runMu    sync.Mutex
+	mu       sync.RWMutex
+	results  []checkResult
+	lastRun  time.Time
+	draining bool
+}
+
+// NewHealthService creates new HealthService for the provided checks.
+// Every time the checks are run their results are recorded in the registry as a gauge (1 when ok, 0 otherwise)
+// and a timer per check.
+func NewHealthService(systemCode, name, description string, registry metrics.Registry, checks []HealthCheck, options ...healthOpt) *HealthService {
+	s := &HealthService{
+		systemCode:    systemCode,
+		name:          name,
+		description:   description,
+		checks:        checks,
+		registry:      registry,
+		timeout:       10 * time.Second,
+		cacheDuration: 10 * time.Second,
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+type healthResult struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	SystemCode    string        `json:"systemCode"`
+	Name          string        `json:"name"`
+	Description   string        `json:"description"`
+	Checks        []checkResult `json:"checks"`
+	OK            bool          `json:"ok"`
+	Severity      uint8         `json:"severity,omitempty"`
+}
+
+type checkResult struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	OK               bool      `json:"ok"`
+	Severity         uint8     `json:"severity"`
+	BusinessImpact   string    `json:"businessImpact"`
+	TechnicalSummary string    `json:"technicalSummary"`
+	PanicGuide       string    `json:"panicGuide"`
+	CheckOutput      string    `json:"checkOutput"`
+	LastUpdated      time.Time `json:"lastUpdated"`
+}
+
+// HealthHandler creates new http.Handler rendering the check results in the FT health JSON format.
+// The handler always responds with 200 as the overall state is part of the body.
+func (s *HealthService) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		results := s.run()
+		health := healthResult{
+			SchemaVersion: 1,
+			SystemCode:    s.systemCode,
+			Name:          s.name,
+			Description:   s.description,
+			Checks:        results,
+			OK:            true,
+		}
+		for _, r := range results {
+			if r.OK {
+				continue
+			}
+			if health.OK || r.Severity < health.Severity {
+				health.Severity = r.Severity
+			}
+			health.OK = false
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(health)
+	})
+}
+
+// SetDraining marks the service as shutting down. While draining the good-to-go endpoint responds with 503
+// regardless of the check results, so load balancers stop sending new traffic.
+func (s *HealthService) SetDraining(draining bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = draining
+}
+
+func (s *HealthService) isDraining() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.draining
+}
+
+// GTGHandler creates new http.Handler responding with 200 when all checks pass and 503 otherwise.
+func (s *HealthService) GTGHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=US-ASCII")
+		w.Header().Set("Cache-Control", "no-store")
+
+		if s.isDraining() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("service is shutting down"))
+			return
+		}
+
+		var failures []string
+		for _, r := range s.run() {
+			if !r.OK {
+				failures = append(failures, fmt.Sprintf("%s: %s", r.Name, r.CheckOutput))
+			}
+		}
+		if len(failures) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(strings.Join(failures, "\n")))
+			return
+		}
+		_, _ = w.Write([]byte("OK"))
+	})
+}
+
+// run returns the cached check results or runs the checks concurrently if the cache has expired.
+func (s *HealthService) run() []checkResult {
+	if results, ok := s.cached(); ok {
+		return results
+	}
+
+	// only one caller runs the checks, the others wait and reuse its results
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	if results, ok := s.cached(); ok {
+		return results
+	}
+
+	results := make([]checkResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = s.runCheck(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.results = results
+	s.lastRun = time.Now()
+	s.mu.Unlock()
+	return results
+}
+
+func (s *HealthService) cached() ([]checkResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.results == nil || time.Since(s.lastRun) >= s.cacheDuration {
+		return nil, false
+	}
+	return s.results, true
+}
+
+func (s *HealthService) runCheck(check HealthCheck) checkResult {
+	id := check.ID
+	if id == "" {
+		id = check.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	type outcome struct {
+		output string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("check panicked: %v", r)}
+			}
+		}()
+		output, err := check.Checker(ctx)
+		done <- outcome{output, err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o = outcome{err: fmt.Errorf("check timed out after %s", s.timeout)}
+	}
+	metrics.GetOrRegisterTimer("health."+id+".duration", s.registry).UpdateSince(start)
+
+	result := checkResult{
+		ID:               id,
+		Name:             check.Name,
+		OK:               o.err == nil,
+		Severity:         check.Severity,
+		BusinessImpact:   check.BusinessImpact,
+		TechnicalSummary: check.TechnicalSummary,
+		PanicGuide:       check.PanicGuide,
+		CheckOutput:      o.output,
+		LastUpdated:      time.Now(),
+	}
+	ok := metrics.GetOrRegisterGauge("health."+id+".ok", s.registry)
+	if o.err != nil {
+		result.CheckOutput = o.err.Error()
+		ok.Update(0)
+	} else {
+		ok.Update(1)
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package httphandlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	r := metrics.NewRegistry()
+	s := NewHealthService("test-system", "Test Service", "Service under test", r, []HealthCheck{
+		{
+			ID:               "db",
+			Name:             "Database",
+			Severity:         2,
+			BusinessImpact:   "No content",
+			TechnicalSummary: "Checks the database",
+			PanicGuide:       "https://runbooks.ft.com/test-system",
+			Checker:          func(ctx context.Context) (string, error) { return "", errors.New("connection refused") },
+		},
+		{
+			ID:       "queue",
+			Name:     "Queue",
+			Severity: 3,
+			Checker:  func(ctx context.Context) (string, error) { return "queue reachable", nil },
+		},
+		{
+			ID:       "slow",
+			Name:     "Slow",
+			Severity: 1,
+			Checker: func(ctx context.Context) (string, error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+		},
+	}, HealthCheckTimeout(10*time.Millisecond))
+
+	resp := httptest.NewRecorder()
+	s.HealthHandler().ServeHTTP(resp, httptest.NewRequest("GET", HealthPath, nil))
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("application/json", resp.Header().Get("Content-Type"))
+
+	var health healthResult
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &health))
+	assert.Equal(1, health.SchemaVersion)
+	assert.Equal("test-system", health.SystemCode)
+	assert.False(health.OK)
+	assert.Equal(uint8(1), health.Severity, "Overall severity should be the most severe failing check")
+	assert.Len(health.Checks, 3)
+
+	assert.Equal("db", health.Checks[0].ID)
+	assert.False(health.Checks[0].OK)
+	assert.Equal("connection refused", health.Checks[0].CheckOutput)
+	assert.Equal("https://runbooks.ft.com/test-system", health.Checks[0].PanicGuide)
+	assert.True(health.Checks[1].OK)
+	assert.Equal("queue reachable", health.Checks[1].CheckOutput)
+	assert.False(health.Checks[2].OK)
+
+	assert.Equal(int64(0), metrics.GetOrRegisterGauge("health.db.ok", r).Value())
+	assert.Equal(int64(1), metrics.GetOrRegisterGauge("health.queue.ok", r).Value())
+	assert.Equal(int64(1), metrics.GetOrRegisterTimer("health.queue.duration", r).Count())
+}
+
+func TestGTGHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	healthy := true
+	s := NewHealthService("test-system", "Test Service", "", metrics.NewRegistry(), []HealthCheck{
+		{
+			ID:   "dependency",
+			Name: "Dependency",
+			Checker: func(ctx context.Context) (string, error) {
+				if healthy {
+					return "", nil
+				}
+				return "", errors.New("dependency is down")
+			},
+		},
+	}, HealthCheckCacheDuration(0))
+
+	resp := httptest.NewRecorder()
+	s.GTGHandler().ServeHTTP(resp, httptest.NewRequest("GET", GTGPath, nil))
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal("OK", resp.Body.String())
+
+	healthy = false
+	resp = httptest.NewRecorder()
+	s.GTGHandler().ServeHTTP(resp, httptest.NewRequest("GET", GTGPath, nil))
+	assert.Equal(http.StatusServiceUnavailable, resp.Code)
+	assert.Equal("Dependency: dependency is down", resp.Body.String())
+}
+
+func TestHealthChecksAreCached(t *testing.T) {
+	assert := assert.New(t)
+
+	var runs int32
+	s := NewHealthService("test-system", "Test Service", "", metrics.NewRegistry(), []HealthCheck{
+		{
+			ID: "counted",
+			Checker: func(ctx context.Context) (string, error) {
+				atomic.AddInt32(&runs, 1)
+				return "", nil
+			},
+		},
+	}, HealthCheckCacheDuration(time.Minute))
+
+	for i := 0; i < 3; i++ {
+		s.GTGHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", GTGPath, nil))
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&runs), "Checks should run once while the results are cached")
+}
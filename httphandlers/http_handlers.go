@@ -1,7 +1,6 @@
 package httphandlers
 
 import (
-	"bufio"
 	"net"
 	"net/http"
 	"regexp"
@@ -13,30 +12,36 @@ import (
 	"github.com/rcrowley/go-metrics"
 )
 
-var headerDenyList = []*regexp.Regexp{
-	regexp.MustCompile("(?i:^User-Agent$)"),
-	regexp.MustCompile("(?i:^Referer$)"),
-	regexp.MustCompile("(?i:^X-Request-Id$)"),
-	regexp.MustCompile("(?i:^X-Api-Key$)"),
-	regexp.MustCompile("(?i:^X-Varnish$)"),
-	regexp.MustCompile("(?i:^X-Timer$)"),
-	regexp.MustCompile("(?i:^Connection$)"),
-	regexp.MustCompile("(?i:^Content-Length$)"),
-	regexp.MustCompile("(?i:^Cdn-Loop$)"),
-	regexp.MustCompile("(?i:^Fastly)"),
+// HTTPMetricsHandler records metrics for each request
+func HTTPMetricsHandler(registry metrics.Registry, h http.Handler, options ...metricsOpt) http.Handler {
+	handler := httpMetricsHandler{registry: registry, handler: h}
+	for _, opt := range options {
+		opt(&handler)
+	}
+	return handler
 }
 
-// HTTPMetricsHandler records metrics for each request
-func HTTPMetricsHandler(registry metrics.Registry, h http.Handler) http.Handler {
-	return httpMetricsHandler{registry, h}
+type metricsOpt func(h *httpMetricsHandler)
+
+// ExcludePreflightRequests creates a HTTPMetricsHandler option that doesn't time the CORS preflight requests,
+// so they don't skew the OPTIONS timer.
+func ExcludePreflightRequests() metricsOpt { // nolint:golint // we don't want metricsOpt exported
+	return func(h *httpMetricsHandler) {
+		h.excludePreflight = true
+	}
 }
 
 type httpMetricsHandler struct {
-	registry metrics.Registry
-	handler  http.Handler
+	registry         metrics.Registry
+	handler          http.Handler
+	excludePreflight bool
 }
 
 func (h httpMetricsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h.excludePreflight && isPreflight(req) {
+		h.handler.ServeHTTP(w, req)
+		return
+	}
 	t := metrics.GetOrRegisterTimer(req.Method, h.registry)
 	t.Time(func() { h.handler.ServeHTTP(w, req) })
 }
@@ -53,14 +58,20 @@ type HeaderFilter func(key string) bool
 // The callback will be executed after the request has been handled and before creating the new log entry
 func FilterHeaders(fn HeaderFilter) handlerOpt { // nolint:golint // we don't want handlerOpt exported
 	return func(h *transactionAwareRequestLoggingHandler) {
-		h.filterHeadersFn = fn
+		h.requestHeaders.fn = fn
 	}
 }
 
 // TransactionAwareRequestLoggingHandler creates new http.Handler that would add log entries to the provided logger in structured format.
 // The handler would search for transactionID in the request headers and will generate one if it doesn't find any.
 func TransactionAwareRequestLoggingHandler(log *logger.UPPLogger, handler http.Handler, options ...handlerOpt) http.Handler {
-	h := transactionAwareRequestLoggingHandler{logger: log, handler: handler, filterHeadersFn: nil}
+	h := transactionAwareRequestLoggingHandler{
+		logger:         log,
+		handler:        handler,
+		requestHeaders: headerFilter{deny: DefaultHeaderDenyList()},
+		sampleRate:     1,
+		contentIDFn:    URIUUIDs,
+	}
 	for _, opt := range options {
 		opt(&h)
 	}
@@ -68,26 +79,78 @@ func TransactionAwareRequestLoggingHandler(log *logger.UPPLogger, handler http.H
 }
 
 type transactionAwareRequestLoggingHandler struct {
-	logger          *logger.UPPLogger
-	handler         http.Handler
-	filterHeadersFn HeaderFilter
+	logger                  *logger.UPPLogger
+	handler                 http.Handler
+	requestHeaders          headerFilter
+	excludedPaths           map[string]bool
+	sampleRate              float64
+	routeSamplers           []*routeSampler
+	slowThreshold           time.Duration
+	slowRoutes              []slowRoute
+	levelByStatus           bool
+	registry                metrics.Registry
+	watchdogThreshold       time.Duration
+	headerRedactor          *headerRedactor
+	queryRedaction          *QueryRedaction
+	responseHeaders         *headerFilter
+	bodyCapture             *BodyCapture
+	fieldMapper             FieldMapper
+	accessLog               *accessLogWriter
+	contentIDFn             ContentIDExtractor
+	maxContentIDs           int
+	tlsDetails              bool
+	logRequestStart         bool
+	requestStartRoutes      []string
+	logHijacked             bool
+	transactionIDValidation *TransactionIDValidation
+	transactionIDGenerator  TransactionIDGenerator
+	transactionIDHeaders    []string
 }
 
 func (h transactionAwareRequestLoggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	transactionID := transactionidutils.GetTransactionIDFromRequest(req)
+	transactionID, originalTransactionID, rejected := h.transactionID(req)
 	w.Header().Set(transactionidutils.TransactionIDHeader, transactionID)
+	handler := h.handler
+	if rejected {
+		handler = http.HandlerFunc(rejectTransactionID)
+	}
+
+	ctx, customFields := newLogContext(withConnectionRequest(req.Context()), h.logEntry(map[string]interface{}{"transaction_id": transactionID}))
+	if originalTransactionID != "" {
+		customFields.setHandlerField("original_transaction_id", originalTransactionID)
+	}
+	req = req.WithContext(transactionidutils.TransactionAwareContext(ctx, transactionID))
 
+	h.logStart(req, transactionID)
 	t := time.Now()
+	stopWatchdog := h.startWatchdog(req, transactionID, t)
 	loggingResponseWriter := wrapWriter(w)
-	h.handler.ServeHTTP(loggingResponseWriter, req)
+	loggingResponseWriter.onHijack(h.hijackHook(req, transactionID))
+	body := h.wrapRequestBody(req, loggingResponseWriter)
+	handler.ServeHTTP(loggingResponseWriter, req)
 	duration := time.Since(t)
-	h.writeRequestLog(req, duration, loggingResponseWriter.Status(), loggingResponseWriter.Size())
+	stopWatchdog()
+	status := loggingResponseWriter.Status()
+	// the client can go away at any time, so whether it did is checked once for the metrics, sampling and log entry
+	aborted := clientAborted(req)
+	if aborted {
+		status = statusClientClosedRequest
+		h.countAbortedRequest()
+	}
+	if !h.shouldLog(req, status, duration) {
+		return
+	}
+	h.writeRequestLog(req, duration, status, aborted, loggingResponseWriter, body, customFields)
 }
 
-// writeRequestLog creates an info log entry in the logger for the provided request
+// writeRequestLog creates a log entry in the logger for the provided request
 // responseTime is the time it took to handle the request
-// status and size are used to provide the response HTTP status and size.
-func (h transactionAwareRequestLoggingHandler) writeRequestLog(req *http.Request, responseTime time.Duration, status, size int) {
+// status is the logged status, statusClientClosedRequest if aborted is true because the client went away.
+// w is used to provide the response size, headers and trailers.
+// body is the wrapper of the request body, nil if the request has no body.
+// customFields are the fields inner handlers attached to the request.
+func (h transactionAwareRequestLoggingHandler) writeRequestLog(req *http.Request, responseTime time.Duration, status int, aborted bool, w loggingResponseWriter, body *requestBodyReader, customFields *logFields) {
+	size := w.Size()
 	transactionID := req.Header.Get(transactionidutils.TransactionIDHeader)
 	url := *req.URL
 	username := ""
@@ -114,8 +177,11 @@ func (h transactionAwareRequestLoggingHandler) writeRequestLog(req *http.Request
 	if uri == "" {
 		uri = url.RequestURI()
 	}
+	if h.queryRedaction != nil {
+		uri = h.queryRedaction.redactURI(uri)
+	}
 
-	entry := h.logger.WithFields(map[string]interface{}{
+	fields := map[string]interface{}{
 		"responsetime":   int64(responseTime.Seconds() * 1000),
 		"host":           host,
 		"username":       username,
@@ -127,141 +193,81 @@ func (h transactionAwareRequestLoggingHandler) writeRequestLog(req *http.Request
 		"size":           size,
 		"referer":        req.Referer(),
 		"userAgent":      req.UserAgent(),
-	})
-
-	headers := getRequestHeaders(req, h.filterHeadersFn)
-	if len(headers) != 0 {
-		entry = entry.WithField("headers", headers)
+	}
+	addDeliveryFields(fields, aborted, w)
+	addRequestBodyFields(fields, req, body)
+	h.addConnectionFields(fields, req)
+
+	slow := h.isSlow(req, responseTime)
+	if slow {
+		fields["slow"] = true
+		h.countSlowRequest()
 	}
 
-	uuids := getUUIDsFromURI(uri)
-	if len(uuids) > 0 {
-		entry = entry.WithUUID(strings.Join(uuids, ","))
+	if h.accessLog != nil {
+		h.accessLog.write(req, time.Now().Add(-responseTime), host, username, uri, status, size)
+		return
 	}
 
-	// log the final result
-	entry.Info("")
-}
+	headers := getHeaders(req.Header, h.requestHeaders, h.headerRedactor)
+	if h.bodyCapture != nil && h.bodyCapture.DebugHeader != "" {
+		delete(headers, http.CanonicalHeaderKey(h.bodyCapture.DebugHeader))
+	}
+	if len(headers) != 0 {
+		fields["headers"] = headers
+	}
 
-func wrapWriter(w http.ResponseWriter) loggingResponseWriter {
-	var logger loggingResponseWriter = &responseLogger{w: w}
-	if _, ok := w.(http.Hijacker); ok {
-		logger = &hijackLogger{responseLogger{w: w}}
+	trailers := w.trailers()
+	if h.responseHeaders != nil {
+		headers := getHeaders(w.Header(), *h.responseHeaders, h.headerRedactor)
+		for key := range headers {
+			if _, ok := trailers[key]; ok || strings.HasPrefix(key, http.TrailerPrefix) {
+				delete(headers, key)
+			}
+		}
+		if len(headers) != 0 {
+			fields["response_headers"] = headers
+		}
 	}
-	h, ok1 := logger.(http.Hijacker)
-	c, ok2 := w.(http.CloseNotifier)
-	if ok1 && ok2 {
-		return hijackCloseNotifier{logger, h, c}
+	if len(trailers) != 0 {
+		filter := headerFilter{deny: DefaultResponseHeaderDenyList()}
+		if h.responseHeaders != nil {
+			filter = *h.responseHeaders
+		}
+		if trailers := getHeaders(trailers, filter, h.headerRedactor); len(trailers) != 0 {
+			fields["trailers"] = trailers
+		}
 	}
-	if ok2 {
-		return &closeNotifyWriter{logger, c}
+	if interim := w.informational(); len(interim) != 0 {
+		fields["informational"] = interim
 	}
-	return logger
-}
-
-type loggingResponseWriter interface {
-	http.ResponseWriter
-	http.Flusher
-	Status() int
-	Size() int
-}
-
-// responseLogger is wrapper of http.ResponseWriter that keeps track of its HTTP
-// status code and body size
-type responseLogger struct {
-	w      http.ResponseWriter
-	status int
-	size   int
-}
-
-func (l *responseLogger) Header() http.Header {
-	return l.w.Header()
-}
-
-func (l *responseLogger) Write(b []byte) (int, error) {
-	if l.status == 0 {
-		// The status will be StatusOK if WriteHeader has not been called yet
-		l.status = http.StatusOK
+	if hints := w.earlyHints(); len(hints) != 0 {
+		fields["early_hints"] = hints
 	}
-	size, err := l.w.Write(b)
-	l.size += size
-	return size, err
-}
-
-func (l *responseLogger) WriteHeader(s int) {
-	l.w.WriteHeader(s)
-	l.status = s
-}
-
-func (l *responseLogger) Status() int {
-	return l.status
-}
-
-func (l *responseLogger) Size() int {
-	return l.size
-}
 
-func (l *responseLogger) Flush() {
-	f, ok := l.w.(http.Flusher)
-	if ok {
-		f.Flush()
+	if h.bodyCapture != nil && h.bodyCapture.captured(req, status) {
+		if body != nil && body.capture != nil && len(body.capture.data) > 0 {
+			fields["request_body"] = h.bodyCapture.field(req.Header.Get("Content-Type"), body.capture)
+		}
+		if b := w.capturedBody(); b != nil && len(b.data) > 0 {
+			fields["response_body"] = h.bodyCapture.field(w.Header().Get("Content-Type"), b)
+		}
 	}
-}
-
-type hijackLogger struct {
-	responseLogger
-}
 
-func (l *hijackLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	h := l.responseLogger.w.(http.Hijacker)
-	conn, rw, err := h.Hijack()
-	if err == nil && l.responseLogger.status == 0 {
-		// The status will be StatusSwitchingProtocols if there was no error and
-		// WriteHeader has not been called yet
-		l.responseLogger.status = http.StatusSwitchingProtocols
+	if uuids := h.contentIDs(uri); len(uuids) > 0 {
+		fields["uuid"] = strings.Join(uuids, ",")
 	}
-	return conn, rw, err
-}
 
-type closeNotifyWriter struct {
-	loggingResponseWriter
-	http.CloseNotifier
-}
+	customFields.mergeInto(fields)
 
-type hijackCloseNotifier struct {
-	loggingResponseWriter
-	http.Hijacker
-	http.CloseNotifier
+	// log the final result
+	h.writeEntry(h.logEntry(fields), status, slow)
 }
 
+// uuidRegexp matches v1 to v8 versions of the UUID standard including usage of capital letters
+var uuidRegexp = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-8][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}`)
+
 // getUUIDsFromURI parses the given uri and is looking for uuids
 func getUUIDsFromURI(uri string) []string {
-	// we use regex that matches v1 to v5 versions of the UUID standard including usage of capital letters
-	re := regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[1-5][0-9a-fA-F]{3}-[89abAB][0-9a-fA-F]{3}-[0-9a-fA-F]{12}`)
-	return re.FindAllString(uri, -1)
-}
-
-func getRequestHeaders(req *http.Request, additionalFilterFn HeaderFilter) map[string]string {
-
-	allowedFn := func(key string) bool {
-		for _, r := range headerDenyList {
-			if r.MatchString(key) {
-				return false
-			}
-		}
-		if additionalFilterFn != nil {
-			return additionalFilterFn(key)
-		}
-		return true
-	}
-
-	headers := map[string]string{}
-	for key, val := range req.Header {
-		if !allowedFn(key) {
-			continue
-		}
-
-		headers[key] = strings.Join(val, ", ")
-	}
-	return headers
+	return uuidRegexp.FindAllString(uri, -1)
 }
@@ -51,6 +51,23 @@ func TestHttpRequestsAreTimedAndCountedForExistingTimer(t *testing.T) {
 
 }
 
+func TestHTTPMetricsHandlerExcludePreflightRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	r := metrics.NewRegistry()
+	handler := HTTPMetricsHandler(r, CORSHandler(CORSConfig{AllowedOrigins: []string{"*"}}, innerHandler{Status: http.StatusOK}), ExcludePreflightRequests())
+
+	preflight := httptest.NewRequest("OPTIONS", "/content", nil)
+	preflight.Header.Set("Origin", "https://www.ft.com")
+	preflight.Header.Set("Access-Control-Request-Method", "GET")
+	handler.ServeHTTP(httptest.NewRecorder(), preflight)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("OPTIONS", "/content", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/content", nil))
+
+	assert.Equal(int64(1), metrics.GetOrRegisterTimer("OPTIONS", r).Count(), "Only the non preflight OPTIONS request should be timed")
+	assert.Equal(int64(1), metrics.GetOrRegisterTimer("GET", r).Count())
+}
+
 // Looking at the gorilla/mux CombinedLoggingHandler, the only test is for the WriteCombinedLog function, so doing the same here
 // (this test inspired by their test)
 func TestWriteLog(t *testing.T) {
@@ -249,14 +266,14 @@ func TestWriteLog(t *testing.T) {
 				"service_name":"test-service"}`,
 		},
 		{
-			name:       "log with two uuid",
+			name:       "log with duplicated uuid",
 			url:        "https://api.ft.com/content/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe/annotations/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe/",
 			respTime:   time.Millisecond * 123,
 			remoteAddr: "192.168.100.11",
 			headers: map[string][]string{
 				"X-Request-Id": {"KnownTransactionId"},
 			},
-			expectedLog: `{"host":"192.168.100.11", "uuid":"0c2c70cc-b801-11e8-bbc3-ccd7de085ffe,0c2c70cc-b801-11e8-bbc3-ccd7de085ffe",
+			expectedLog: `{"host":"192.168.100.11", "uuid":"0c2c70cc-b801-11e8-bbc3-ccd7de085ffe",
 				"level":"info","method":"GET","protocol":"HTTP/1.1",
 				"size":100,"status":200,"transaction_id":"KnownTransactionId",
 				"uri":"/content/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe/annotations/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe/",
@@ -354,6 +371,16 @@ func TestGetUUIDsFromURI(t *testing.T) {
 			input:    "/lists/notifications?since=2019-10-10T10%3A10%3A39.773Z",
 			expected: nil,
 		},
+		{
+			name:     "v7 uuid",
+			input:    "/content/018f4e2a-7b3c-7d4e-9f10-123456789abc",
+			expected: []string{"018f4e2a-7b3c-7d4e-9f10-123456789abc"},
+		},
+		{
+			name:     "invalid version",
+			input:    "/content/018f4e2a-7b3c-9d4e-9f10-123456789abc",
+			expected: nil,
+		},
 		{
 			name:     "two uuid",
 			input:    "https://api.ft.com/drafts/content/0c2c70cc-b801-11e8-bbc3-ccd7de085ffe/annotations/87645070-7d8a-492e-9695-bf61ac2b4d18",
@@ -0,0 +1,102 @@
+package httphandlers
+
+import (
+	"strings"
+)
+
+// FieldMapper transforms the fields of the entries logged by TransactionAwareRequestLoggingHandler before they are logged,
+// the request log entry as well as the request started, still running and hijacked connection entries and the base fields
+// of the LoggerFromContext entry. It receives the fields named after the FT schema and returns the fields to log.
+type FieldMapper func(fields map[string]interface{}) map[string]interface{}
+
+// MapLogFields creates a handler option that renames the request log fields, e.g. with ECSFields or OTelFields.
+func MapLogFields(fn FieldMapper) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.fieldMapper = fn
+	}
+}
+
+// FTFields is the FieldMapper keeping the FT schema the handler logs by default.
+func FTFields(fields map[string]interface{}) map[string]interface{} {
+	return fields
+}
+
+var ecsFieldNames = map[string]string{
+	"host":             "client.address",
+	"username":         "user.name",
+	"method":           "http.request.method",
+	"transaction_id":   "transaction.id",
+	"uri":              "url.original",
+	"status":           "http.response.status_code",
+	"size":             "http.response.body.bytes",
+	"referer":          "http.request.referrer",
+	"userAgent":        "user_agent.original",
+	"headers":          "http.request.headers",
+	"response_headers": "http.response.headers",
+	"uuid":             "labels.uuid",
+}
+
+// ECSFields is the FieldMapper following the Elastic Common Schema.
+// The response time is logged in nanoseconds as event.duration, the content IDs as labels.uuid.
+func ECSFields(fields map[string]interface{}) map[string]interface{} {
+	res := renameFields(fields, ecsFieldNames)
+	if ms, ok := fields["responsetime"].(int64); ok {
+		delete(res, "responsetime")
+		res["event.duration"] = ms * 1000000
+	}
+	if proto, ok := fields["protocol"].(string); ok {
+		delete(res, "protocol")
+		res["http.version"] = strings.TrimPrefix(proto, "HTTP/")
+	}
+	return res
+}
+
+var otelFieldNames = map[string]string{
+	"host":             "client.address",
+	"username":         "enduser.id",
+	"method":           "http.request.method",
+	"status":           "http.response.status_code",
+	"size":             "http.response.body.size",
+	"referer":          "http.request.header.referer",
+	"userAgent":        "user_agent.original",
+	"headers":          "http.request.header",
+	"response_headers": "http.response.header",
+}
+
+// OTelFields is the FieldMapper following the OpenTelemetry semantic conventions for HTTP servers.
+// The response time is logged in seconds as http.server.request.duration. The transaction_id and uuid fields
+// have no equivalent in the conventions and are logged as they are.
+func OTelFields(fields map[string]interface{}) map[string]interface{} {
+	res := renameFields(fields, otelFieldNames)
+	if ms, ok := fields["responsetime"].(int64); ok {
+		delete(res, "responsetime")
+		res["http.server.request.duration"] = float64(ms) / 1000
+	}
+	if uri, ok := fields["uri"].(string); ok {
+		delete(res, "uri")
+		path, query, hasQuery := strings.Cut(uri, "?")
+		res["url.path"] = path
+		if hasQuery {
+			res["url.query"] = query
+		}
+	}
+	if proto, ok := fields["protocol"].(string); ok {
+		delete(res, "protocol")
+		name, version, _ := strings.Cut(proto, "/")
+		res["network.protocol.name"] = strings.ToLower(name)
+		res["network.protocol.version"] = version
+	}
+	return res
+}
+
+// renameFields copies the fields renaming the ones present in names
+func renameFields(fields map[string]interface{}, names map[string]string) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		if name, ok := names[key]; ok {
+			key = name
+		}
+		res[key] = value
+	}
+	return res
+}
@@ -0,0 +1,136 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapLogFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		mapper   FieldMapper
+		expected map[string]interface{}
+	}{
+		{
+			name:   "FT fields",
+			mapper: FTFields,
+			expected: map[string]interface{}{
+				"host":           "192.0.2.1",
+				"method":         "GET",
+				"protocol":       "HTTP/1.1",
+				"referer":        "http://example.com",
+				"size":           float64(2),
+				"status":         float64(200),
+				"transaction_id": "tid_test",
+				"uri":            "/content?page=2",
+				"userAgent":      "test agent",
+			},
+		},
+		{
+			name:   "ECS fields",
+			mapper: ECSFields,
+			expected: map[string]interface{}{
+				"client.address":            "192.0.2.1",
+				"http.request.method":       "GET",
+				"http.version":              "1.1",
+				"http.request.referrer":     "http://example.com",
+				"http.response.body.bytes":  float64(2),
+				"http.response.status_code": float64(200),
+				"transaction.id":            "tid_test",
+				"url.original":              "/content?page=2",
+				"user_agent.original":       "test agent",
+			},
+		},
+		{
+			name:   "OTel fields",
+			mapper: OTelFields,
+			expected: map[string]interface{}{
+				"client.address":              "192.0.2.1",
+				"http.request.method":         "GET",
+				"network.protocol.name":       "http",
+				"network.protocol.version":    "1.1",
+				"http.request.header.referer": "http://example.com",
+				"http.response.body.size":     float64(2),
+				"http.response.status_code":   float64(200),
+				"transaction_id":              "tid_test",
+				"url.path":                    "/content",
+				"url.query":                   "page=2",
+				"user_agent.original":         "test agent",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			req := httptest.NewRequest("GET", "/content?page=2", nil)
+			req.Header.Set("X-Request-Id", "tid_test")
+			req.Header.Set("Referer", "http://example.com")
+			req.Header.Set("User-Agent", "test agent")
+
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK, Body: []byte("{}")}, MapLogFields(test.mapper))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			fields := entries[0]
+			for _, key := range []string{logger.DefaultKeyTime, "level", "msg", "service_name", "responsetime", "event.duration", "http.server.request.duration"} {
+				delete(fields, key)
+			}
+			assert.Equal(t, test.expected, fields)
+		})
+	}
+}
+
+func TestFieldMappersConvertResponseTime(t *testing.T) {
+	fields := map[string]interface{}{"responsetime": int64(1500)}
+	assert.Equal(t, int64(1500000000), ECSFields(fields)["event.duration"])
+	assert.Equal(t, 1.5, OTelFields(fields)["http.server.request.duration"])
+	assert.NotContains(t, ECSFields(fields), "responsetime")
+}
+
+func TestMapLogFieldsAppliesToEveryEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		entry, _ := LoggerFromContext(req.Context())
+		entry.Info("handling request")
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := TransactionAwareRequestLoggingHandler(log, inner,
+		MapLogFields(ECSFields),
+		LogRequestStart(),
+		InFlightWatchdog(10*time.Millisecond),
+	)
+	req := httptest.NewRequest("GET", "/content/b7b2a08a-7d8a-4a3c-9c1f-2b2e8d1e5f6a", nil)
+	req.Header.Set("X-Request-Id", "tid_mapped")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 4)
+	for _, entry := range entries {
+		assert.Equal("tid_mapped", entry["transaction.id"], entry["msg"])
+		assert.NotContains(entry, "transaction_id", entry["msg"])
+		assert.NotContains(entry, "uri", entry["msg"])
+		assert.NotContains(entry, "uuid", entry["msg"])
+	}
+	assert.Equal("request started", entries[0]["msg"])
+	assert.Equal("/content/b7b2a08a-7d8a-4a3c-9c1f-2b2e8d1e5f6a", entries[0]["url.original"])
+	assert.Equal("handling request", entries[1]["msg"])
+	assert.Equal("request still running", entries[2]["msg"])
+	assert.Equal("GET", entries[2]["http.request.method"])
+	assert.Equal("b7b2a08a-7d8a-4a3c-9c1f-2b2e8d1e5f6a", entries[3]["labels.uuid"])
+}
@@ -0,0 +1,147 @@
+package httphandlers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+// LogRequestStart creates a handler option that logs a "request started" entry as soon as a request is received,
+// for streaming, SSE or websocket endpoints the request log is otherwise written only once the response is complete.
+// Without prefixes the entry is logged for all requests, otherwise only for the requests whose path starts with one of them.
+func LogRequestStart(prefixes ...string) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.logRequestStart = true
+		h.requestStartRoutes = prefixes
+	}
+}
+
+// LogHijackedConnections creates a handler option that logs a "connection upgraded" entry when the inner handler
+// hijacks the connection, e.g. for websockets, and a "connection closed" entry with the bytes read and written
+// once the hijacked connection is closed.
+func LogHijackedConnections() handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.logHijacked = true
+	}
+}
+
+// requestFields returns the fields identifying the request, for the entries logged while it is still running
+func (h transactionAwareRequestLoggingHandler) requestFields(req *http.Request, transactionID string) map[string]interface{} {
+	uri := req.RequestURI
+	if uri == "" {
+		uri = req.URL.RequestURI()
+	}
+	if h.queryRedaction != nil {
+		uri = h.queryRedaction.redactURI(uri)
+	}
+	return map[string]interface{}{
+		"transaction_id": transactionID,
+		"method":         req.Method,
+		"uri":            uri,
+		"protocol":       req.Proto,
+	}
+}
+
+// logEntry creates a log entry with the fields mapped by the FieldMapper, if any
+func (h transactionAwareRequestLoggingHandler) logEntry(fields map[string]interface{}) *logger.LogEntry {
+	if h.fieldMapper != nil {
+		fields = h.fieldMapper(fields)
+	}
+	return h.logger.WithFields(fields)
+}
+
+// withFields returns a copy of fields with the extra ones added
+func withFields(fields, extra map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields)+len(extra))
+	for k, v := range fields {
+		res[k] = v
+	}
+	for k, v := range extra {
+		res[k] = v
+	}
+	return res
+}
+
+// logStart logs the request started entry if it is enabled for the request
+func (h transactionAwareRequestLoggingHandler) logStart(req *http.Request, transactionID string) {
+	if !h.logRequestStart {
+		return
+	}
+	if len(h.requestStartRoutes) > 0 {
+		matched := false
+		for _, prefix := range h.requestStartRoutes {
+			if strings.HasPrefix(req.URL.Path, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return
+		}
+	}
+	h.logEntry(h.requestFields(req, transactionID)).Info("request started")
+}
+
+// hijackHook returns the function logging the upgrade of the request connection and tracking the hijacked connection,
+// nil if hijacked connections are not logged
+func (h transactionAwareRequestLoggingHandler) hijackHook(req *http.Request, transactionID string) func(net.Conn, *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter) {
+	if !h.logHijacked {
+		return nil
+	}
+	fields := h.requestFields(req, transactionID)
+	upgrade := req.Header.Get("Upgrade")
+	return func(conn net.Conn, rw *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter) {
+		h.logEntry(withFields(fields, map[string]interface{}{"upgrade": upgrade})).Info("connection upgraded")
+
+		tracked := &hijackedConn{Conn: conn, start: time.Now(), logClose: func(extra map[string]interface{}) {
+			h.logEntry(withFields(fields, extra)).Info("connection closed")
+		}}
+		// bytes the server already read from the connection are still to be read by the handler
+		buffered, _ := rw.Reader.Peek(rw.Reader.Buffered())
+		tracked.read.Add(int64(len(buffered)))
+		reader := io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), tracked)
+		return tracked, bufio.NewReadWriter(bufio.NewReader(reader), bufio.NewWriter(tracked))
+	}
+}
+
+// hijackedConn counts the bytes transferred on a hijacked connection and logs them when it is closed
+type hijackedConn struct {
+	net.Conn
+	start    time.Time
+	logClose func(fields map[string]interface{})
+	read     atomic.Int64
+	written  atomic.Int64
+	once     sync.Once
+}
+
+func (c *hijackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+func (c *hijackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
+
+func (c *hijackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		c.logClose(map[string]interface{}{
+			"bytes_read":    c.read.Load(),
+			"bytes_written": c.written.Load(),
+			"duration":      time.Since(c.start).Milliseconds(),
+		})
+	})
+	return err
+}
@@ -0,0 +1,127 @@
+package httphandlers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogRequestStart(t *testing.T) {
+	tests := []struct {
+		name            string
+		prefixes        []string
+		path            string
+		expectedEntries int
+	}{
+		{
+			name:            "all requests",
+			path:            "/content",
+			expectedEntries: 2,
+		},
+		{
+			name:            "matching route",
+			prefixes:        []string{"/events"},
+			path:            "/events/stream",
+			expectedEntries: 2,
+		},
+		{
+			name:            "other route",
+			prefixes:        []string{"/events"},
+			path:            "/content",
+			expectedEntries: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			req := httptest.NewRequest("GET", test.path, nil)
+			req.Header.Set("X-Request-Id", "tid_start")
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK}, LogRequestStart(test.prefixes...))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logEntries(t, buf)
+			if !assert.Len(entries, test.expectedEntries) {
+				return
+			}
+			if test.expectedEntries == 2 {
+				assert.Equal("request started", entries[0]["msg"])
+				assert.Equal("tid_start", entries[0]["transaction_id"])
+				assert.Equal(test.path, entries[0]["uri"])
+				assert.Equal("GET", entries[0]["method"])
+			}
+			assert.Equal(float64(http.StatusOK), entries[len(entries)-1]["status"])
+		})
+	}
+}
+
+func TestLogHijackedConnections(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	const upgradeResponse = "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if !assert.NoError(err) {
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString(upgradeResponse)
+		_ = rw.Flush()
+		line, _ := rw.ReadString('\n')
+		_, _ = rw.WriteString(line)
+		_ = rw.Flush()
+	})
+	done := make(chan struct{})
+	handler := TransactionAwareRequestLoggingHandler(log, inner, LogHijackedConnections())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer close(done)
+		handler.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if !assert.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	// the message is sent with the request so the server reads part of it before the connection is hijacked
+	_, err = fmt.Fprint(conn, "GET /echo HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\nX-Request-Id: tid_echo\r\n\r\nping\n")
+	assert.NoError(err)
+	received, err := io.ReadAll(bufio.NewReader(conn))
+	assert.NoError(err)
+	assert.Equal(upgradeResponse+"ping\n", string(received))
+	<-done
+
+	entries := logEntries(t, buf)
+	if !assert.Len(entries, 3) {
+		return
+	}
+	assert.Equal("connection upgraded", entries[0]["msg"])
+	assert.Equal("echo", entries[0]["upgrade"])
+	assert.Equal("tid_echo", entries[0]["transaction_id"])
+	assert.Equal("/echo", entries[0]["uri"])
+
+	assert.Equal("connection closed", entries[1]["msg"])
+	assert.Equal("tid_echo", entries[1]["transaction_id"])
+	assert.Equal(float64(len("ping\n")), entries[1]["bytes_read"])
+	assert.Equal(float64(len(upgradeResponse+"ping\n")), entries[1]["bytes_written"])
+	assert.Contains(entries[1], "duration")
+
+	assert.Equal(float64(http.StatusSwitchingProtocols), entries[2]["status"])
+}
@@ -0,0 +1,87 @@
+package httphandlers
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// QueryRedaction configures the masking of query parameters in the logged URI.
+// Parameter names are matched case-insensitively.
+type QueryRedaction struct {
+	// Params are the names of query parameters which values are masked.
+	Params []string
+	// Patterns mask the values of query parameters which names match.
+	Patterns []*regexp.Regexp
+	// Drop are the names of query parameters removed from the logged URI.
+	Drop []string
+	// DropPatterns remove query parameters which names match.
+	DropPatterns []*regexp.Regexp
+	// StripQuery removes the whole query string from the logged URI.
+	StripQuery bool
+	// Masker produces the replacement of a masked value. Defaults to MaskWith("[REDACTED]").
+	Masker ValueMasker
+}
+
+// RedactQuery creates a handler option that masks or drops query parameters from the logged URI.
+// The redaction is applied before the UUIDs are extracted from the URI.
+func RedactQuery(r QueryRedaction) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		if r.Masker == nil {
+			r.Masker = MaskWith("[REDACTED]")
+		}
+		h.queryRedaction = &r
+	}
+}
+
+// redactURI returns the uri with the query parameters masked or dropped according to the configuration
+func (r *QueryRedaction) redactURI(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	if r.StripQuery {
+		return path
+	}
+
+	params := strings.Split(query, "&")
+	kept := params[:0]
+	for _, param := range params {
+		rawName, rawValue, hasValue := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+
+		if matchesParam(name, r.Drop, r.DropPatterns) {
+			continue
+		}
+		if hasValue && matchesParam(name, r.Params, r.Patterns) {
+			value, err := url.QueryUnescape(rawValue)
+			if err != nil {
+				value = rawValue
+			}
+			param = rawName + "=" + r.Masker(value)
+		}
+		kept = append(kept, param)
+	}
+
+	if len(kept) == 0 {
+		return path
+	}
+	return path + "?" + strings.Join(kept, "&")
+}
+
+func matchesParam(name string, names []string, patterns []*regexp.Regexp) bool {
+	for _, n := range names {
+		if strings.EqualFold(name, n) {
+			return true
+		}
+	}
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
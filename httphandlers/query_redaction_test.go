@@ -0,0 +1,138 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactURI(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction QueryRedaction
+		input     string
+		expected  string
+	}{
+		{
+			name:      "no query",
+			redaction: QueryRedaction{Params: []string{"apiKey"}},
+			input:     "/content/123",
+			expected:  "/content/123",
+		},
+		{
+			name:      "masked parameter",
+			redaction: QueryRedaction{Params: []string{"apiKey"}},
+			input:     "/content?apikey=secret&bindings=v2",
+			expected:  "/content?apikey=[REDACTED]&bindings=v2",
+		},
+		{
+			name:      "masked parameter pattern",
+			redaction: QueryRedaction{Patterns: []*regexp.Regexp{regexp.MustCompile("(?i)token$")}},
+			input:     "/content?accessToken=secret&refreshToken=secret&page=2",
+			expected:  "/content?accessToken=[REDACTED]&refreshToken=[REDACTED]&page=2",
+		},
+		{
+			name:      "dropped parameter",
+			redaction: QueryRedaction{Drop: []string{"email"}},
+			input:     "/users?email=john.doe%40ft.com&active=true",
+			expected:  "/users?active=true",
+		},
+		{
+			name:      "dropped parameter pattern",
+			redaction: QueryRedaction{DropPatterns: []*regexp.Regexp{regexp.MustCompile("^utm_")}},
+			input:     "/content?utm_source=newsletter&utm_medium=email",
+			expected:  "/content",
+		},
+		{
+			name:      "stripped query",
+			redaction: QueryRedaction{StripQuery: true},
+			input:     "/content?apiKey=secret",
+			expected:  "/content",
+		},
+		{
+			name:      "hashed parameter",
+			redaction: QueryRedaction{Params: []string{"email"}, Masker: HashValues()},
+			input:     "/users?email=john.doe%40ft.com",
+			expected:  "/users?email=" + HashValues()("john.doe@ft.com"),
+		},
+		{
+			name:      "parameter without value",
+			redaction: QueryRedaction{Params: []string{"debug"}},
+			input:     "/content?debug",
+			expected:  "/content?debug",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := test.redaction
+			if r.Masker == nil {
+				r.Masker = MaskWith("[REDACTED]")
+			}
+			assert.Equal(t, test.expected, r.redactURI(test.input))
+		})
+	}
+}
+
+func TestRedactQueryBeforeUUIDExtraction(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK}, RedactQuery(QueryRedaction{Params: []string{"userId"}}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/content?userId=0c2c70cc-b801-11e8-bbc3-ccd7de085ffe", nil))
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal("/content?userId=[REDACTED]", entries[0]["uri"])
+	assert.NotContains(entries[0], "uuid")
+}
+
+func TestRedactQueryInWatchdogEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		requestURI bool
+	}{
+		{
+			name:       "server request",
+			requestURI: true,
+		},
+		{
+			name: "request without RequestURI",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK, WaitTime: 50 * time.Millisecond},
+				InFlightWatchdog(10*time.Millisecond),
+				RedactQuery(QueryRedaction{Params: []string{"apiKey"}}),
+			)
+			req := httptest.NewRequest("GET", "/content?apiKey=secret&page=2", nil)
+			if !test.requestURI {
+				req.RequestURI = ""
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logEntries(t, buf)
+			if !assert.Len(entries, 2) {
+				return
+			}
+			assert.Equal("request still running", entries[0]["msg"])
+			assert.Equal("/content?apiKey=[REDACTED]&page=2", entries[0]["uri"])
+			assert.NotContains(buf.String(), "secret")
+		})
+	}
+}
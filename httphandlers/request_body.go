@@ -0,0 +1,56 @@
+package httphandlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// requestBodyReader wraps the request body to account for and capture what the inner handler reads from it
+type requestBodyReader struct {
+	io.ReadCloser
+	capture *bodyBuffer
+	size    int64
+	// consumed is set once the body has been read up to EOF
+	consumed bool
+	// firstRead and lastRead delimit the time spent reading the body
+	firstRead time.Time
+	lastRead  time.Time
+}
+
+func (r *requestBodyReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	if r.firstRead.IsZero() {
+		r.firstRead = start
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.lastRead = time.Now()
+	r.size += int64(n)
+	if errors.Is(err, io.EOF) {
+		r.consumed = true
+	}
+	if r.capture != nil {
+		r.capture.write(p[:n])
+	}
+	return n, err
+}
+
+// readTime returns the time between the first and the last read of the body
+func (r *requestBodyReader) readTime() time.Duration {
+	return r.lastRead.Sub(r.firstRead)
+}
+
+// addRequestBodyFields adds the request body accounting to fields.
+// body is nil if the request had no body.
+func addRequestBodyFields(fields map[string]interface{}, req *http.Request, body *requestBodyReader) {
+	if body == nil {
+		return
+	}
+	fields["request_size"] = body.size
+	if req.ContentLength >= 0 {
+		fields["request_content_length"] = req.ContentLength
+	}
+	fields["request_body_consumed"] = body.consumed
+	fields["request_read_time"] = body.readTime().Milliseconds()
+}
@@ -0,0 +1,119 @@
+package httphandlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowReader returns one byte per read after waiting for delay
+type slowReader struct {
+	data  string
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestRequestBodyAccounting(t *testing.T) {
+	tests := []struct {
+		name                  string
+		body                  io.Reader
+		contentLength         int64
+		read                  int64
+		expectedFields        bool
+		expectedSize          float64
+		expectedContentLength interface{}
+		expectedConsumed      bool
+		minReadTime           float64
+	}{
+		{
+			name:           "no body",
+			read:           -1,
+			expectedFields: false,
+		},
+		{
+			name:                  "fully read",
+			body:                  strings.NewReader("hello world"),
+			contentLength:         11,
+			read:                  -1,
+			expectedFields:        true,
+			expectedSize:          11,
+			expectedContentLength: float64(11),
+			expectedConsumed:      true,
+		},
+		{
+			name:                  "partially read",
+			body:                  strings.NewReader("hello world"),
+			contentLength:         11,
+			read:                  5,
+			expectedFields:        true,
+			expectedSize:          5,
+			expectedContentLength: float64(11),
+			expectedConsumed:      false,
+		},
+		{
+			name:             "unknown length slow upload",
+			body:             &slowReader{data: "abc", delay: 20 * time.Millisecond},
+			contentLength:    -1,
+			read:             -1,
+			expectedFields:   true,
+			expectedSize:     3,
+			expectedConsumed: true,
+			minReadTime:      40,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if test.read < 0 {
+					_, _ = io.Copy(io.Discard, req.Body)
+				} else {
+					_, _ = io.CopyN(io.Discard, req.Body, test.read)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("POST", "/content", test.body)
+			if test.body != nil {
+				req.ContentLength = test.contentLength
+			}
+			TransactionAwareRequestLoggingHandler(log, inner).ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logEntries(t, buf)
+			assert.Len(entries, 1)
+			entry := entries[0]
+			if !test.expectedFields {
+				assert.NotContains(entry, "request_size")
+				assert.NotContains(entry, "request_content_length")
+				assert.NotContains(entry, "request_body_consumed")
+				assert.NotContains(entry, "request_read_time")
+				return
+			}
+			assert.Equal(test.expectedSize, entry["request_size"])
+			assert.Equal(test.expectedContentLength, entry["request_content_length"])
+			assert.Equal(test.expectedConsumed, entry["request_body_consumed"])
+			assert.GreaterOrEqual(entry["request_read_time"], test.minReadTime)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package httphandlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+type gzipRoundTripperOpt func(rt *requestBodyGzipRoundTripper)
+
+// GzipMinSize sets the minimum request body size in bytes for the body to be compressed.
+// Bodies with unknown length are always compressed.
+func GzipMinSize(size int64) gzipRoundTripperOpt { // nolint:golint // we don't want gzipRoundTripperOpt exported
+	return func(rt *requestBodyGzipRoundTripper) {
+		rt.minSize = size
+	}
+}
+
+// GzipLevel sets the compression level used for request bodies, see compress/gzip for the accepted values.
+func GzipLevel(level int) gzipRoundTripperOpt { // nolint:golint // we don't want gzipRoundTripperOpt exported
+	return func(rt *requestBodyGzipRoundTripper) {
+		rt.level = level
+	}
+}
+
+// RequestBodyGzipRoundTripper creates new http.RoundTripper that gzips outgoing request bodies and sets the Content-Encoding header.
+// It is the client side counterpart of RequestBodyGzipHandler.
+// The body is compressed while it is being sent so it is never buffered as a whole. Requests that already have
+// a Content-Encoding or a body smaller than the minimum size are sent unchanged.
+// If next is nil http.DefaultTransport is used.
+func RequestBodyGzipRoundTripper(next http.RoundTripper, options ...gzipRoundTripperOpt) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	rt := requestBodyGzipRoundTripper{next: next, minSize: 1024, level: gzip.DefaultCompression}
+	for _, opt := range options {
+		opt(&rt)
+	}
+	return rt
+}
+
+type requestBodyGzipRoundTripper struct {
+	next    http.RoundTripper
+	minSize int64
+	level   int
+}
+
+func (rt requestBodyGzipRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return rt.next.RoundTrip(req)
+	}
+	// For client requests a zero ContentLength with a non-nil Body means the length is unknown
+	if req.ContentLength > 0 && req.ContentLength < rt.minSize {
+		return rt.next.RoundTrip(req)
+	}
+
+	// RoundTrip must not modify the request, so we work on a copy
+	zipped := req.Clone(req.Context())
+	zipped.Body = rt.gzipBody(req.Body)
+	zipped.ContentLength = -1
+	zipped.Header.Del("Content-Length")
+	zipped.Header.Set("Content-Encoding", "gzip")
+	if req.GetBody != nil {
+		getBody := req.GetBody
+		zipped.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return rt.gzipBody(body), nil
+		}
+	}
+	return rt.next.RoundTrip(zipped)
+}
+
+// gzipBody returns a reader producing the gzipped content of body.
+// The compression happens in a separate goroutine as the returned reader is consumed.
+func (rt requestBodyGzipRoundTripper) gzipBody(body io.ReadCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		gzw, err := gzip.NewWriterLevel(pw, rt.level)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err = io.Copy(gzw, body); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(gzw.Close())
+	}()
+	return gzipBody{PipeReader: pr, src: body}
+}
+
+// gzipBody closes the original request body together with the pipe it is read through
+type gzipBody struct {
+	*io.PipeReader
+	src io.Closer
+}
+
+func (b gzipBody) Close() error {
+	_ = b.PipeReader.Close()
+	return b.src.Close()
+}
@@ -0,0 +1,99 @@
+package httphandlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestBodyGzipRoundTripper(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, testCase := range []struct {
+		name             string
+		body             string
+		headers          map[string]string
+		expectedEncoding string
+	}{
+		{
+			name:             "large body is gzipped",
+			body:             strings.Repeat("hello world ", 100),
+			expectedEncoding: "gzip",
+		},
+		{
+			name:             "small body is sent unchanged",
+			body:             "hello world",
+			expectedEncoding: "",
+		},
+		{
+			name:             "encoded body is sent unchanged",
+			body:             strings.Repeat("hello world ", 100),
+			headers:          map[string]string{"Content-Encoding": "br"},
+			expectedEncoding: "br",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			var encoding string
+			var actual []byte
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				encoding = r.Header.Get("Content-Encoding")
+				actual, _ = io.ReadAll(r.Body)
+			})
+			ts := httptest.NewServer(RequestBodyGzipHandler(handler))
+			defer ts.Close()
+
+			client := &http.Client{Transport: RequestBodyGzipRoundTripper(nil, GzipMinSize(100))}
+			req, err := http.NewRequest("PUT", ts.URL, strings.NewReader(testCase.body))
+			assert.NoError(err)
+			for h, v := range testCase.headers {
+				req.Header.Set(h, v)
+			}
+
+			resp, err := client.Do(req)
+			assert.NoError(err)
+			resp.Body.Close()
+
+			if testCase.expectedEncoding == "gzip" {
+				// the gzip handler removes the header after unzipping
+				assert.Equal("", encoding)
+			} else {
+				assert.Equal(testCase.expectedEncoding, encoding)
+			}
+			assert.Equal(testCase.body, string(actual))
+		})
+	}
+}
+
+func TestRequestBodyGzipRoundTripperPreservesGetBody(t *testing.T) {
+	assert := assert.New(t)
+
+	var sent *http.Request
+	next := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		_, _ = io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	body := strings.Repeat("hello world ", 100)
+	req, err := http.NewRequest("POST", "http://example.com", bytes.NewReader([]byte(body)))
+	assert.NoError(err)
+
+	_, err = RequestBodyGzipRoundTripper(next, GzipMinSize(10)).RoundTrip(req)
+	assert.NoError(err)
+	assert.Equal("gzip", sent.Header.Get("Content-Encoding"))
+	assert.Equal(int64(-1), sent.ContentLength)
+	assert.Equal("", req.Header.Get("Content-Encoding"), "The original request should not be modified")
+
+	assert.NotNil(sent.GetBody)
+	retry, err := sent.GetBody()
+	assert.NoError(err)
+	zipped, err := io.ReadAll(retry)
+	assert.NoError(err)
+	assert.Equal(gz([]byte(body)), zipped)
+}
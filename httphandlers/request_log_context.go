@@ -0,0 +1,108 @@
+package httphandlers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+type logContextKey int
+
+const (
+	// logFieldsKey is the key used to store the custom fields of the request log entry on the context
+	logFieldsKey logContextKey = iota
+	// logEntryKey is the key used to store the request scoped log entry on the context
+	logEntryKey
+)
+
+// reservedLogFields are all the fields the logging handler may log itself, including the ones it only logs
+// for some requests or with some options. Custom fields with these names are ignored.
+var reservedLogFields = map[string]bool{
+	"responsetime": true, "host": true, "username": true, "method": true, "transaction_id": true,
+	"original_transaction_id": true, "uri": true, "protocol": true, "status": true, "size": true,
+	"referer": true, "userAgent": true, "uuid": true, "slow": true,
+	"client_aborted": true, "response_status": true, "write_error": true, "attempted_size": true,
+	"request_size": true, "request_content_length": true, "request_body_consumed": true, "request_read_time": true,
+	"connection_id": true, "connection_requests": true, "connection_reused": true,
+	"tls_version": true, "tls_cipher": true, "tls_server_name": true, "tls_alpn": true, "tls_resumed": true,
+	"client_cert_subject": true, "client_cert_issuer": true, "client_cert_serial": true,
+	"headers": true, "response_headers": true, "trailers": true, "informational": true, "early_hints": true,
+	"request_body": true, "response_body": true,
+}
+
+// logFields is the bag of custom fields inner handlers attach to the request log entry
+type logFields struct {
+	mu     sync.Mutex
+	fields map[string]interface{}
+	// handlerFields are the fields the logging handler knows before calling the inner handler,
+	// they can't be overridden by the custom fields
+	handlerFields map[string]interface{}
+}
+
+// AddLogField attaches a field to the log entry TransactionAwareRequestLoggingHandler writes for the request
+// the context belongs to. Fields named as the ones the handler may log itself are ignored,
+// even when the handler doesn't log them for this request.
+// It does nothing if the context doesn't come from a request handled by TransactionAwareRequestLoggingHandler.
+func AddLogField(ctx context.Context, key string, value interface{}) {
+	AddLogFields(ctx, map[string]interface{}{key: value})
+}
+
+// AddLogFields attaches several fields to the request log entry, see AddLogField.
+func AddLogFields(ctx context.Context, fields map[string]interface{}) {
+	bag, ok := ctx.Value(logFieldsKey).(*logFields)
+	if !ok {
+		return
+	}
+	bag.mu.Lock()
+	defer bag.mu.Unlock()
+	if bag.fields == nil {
+		bag.fields = make(map[string]interface{}, len(fields))
+	}
+	for k, v := range fields {
+		bag.fields[k] = v
+	}
+}
+
+// LoggerFromContext returns a log entry of the logger used by TransactionAwareRequestLoggingHandler
+// pre-populated with the transaction ID of the request the context belongs to.
+// It returns false if the context doesn't come from a request handled by TransactionAwareRequestLoggingHandler.
+func LoggerFromContext(ctx context.Context) (*logger.LogEntry, bool) {
+	entry, ok := ctx.Value(logEntryKey).(*logger.LogEntry)
+	return entry, ok
+}
+
+// newLogContext stores the custom fields bag and the request scoped log entry on the context
+func newLogContext(ctx context.Context, entry *logger.LogEntry) (context.Context, *logFields) {
+	bag := &logFields{}
+	ctx = context.WithValue(ctx, logFieldsKey, bag)
+	ctx = context.WithValue(ctx, logEntryKey, entry)
+	return ctx, bag
+}
+
+// setHandlerField sets a field of the logging handler itself
+func (b *logFields) setHandlerField(key string, value interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlerFields == nil {
+		b.handlerFields = map[string]interface{}{}
+	}
+	b.handlerFields[key] = value
+}
+
+// mergeInto adds the handler and custom fields to fields without overriding the existing ones,
+// the custom fields named as reserved fields are dropped
+func (b *logFields) mergeInto(fields map[string]interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for k, v := range b.handlerFields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	for k, v := range b.fields {
+		if _, ok := fields[k]; !ok && !reservedLogFields[k] {
+			fields[k] = v
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package httphandlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomLogFields(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		AddLogField(req.Context(), "publish_reference", "tid_publish")
+		AddLogFields(req.Context(), map[string]interface{}{
+			"upstream_system": "methode",
+			"status":          999,
+		})
+
+		entry, ok := LoggerFromContext(req.Context())
+		assert.True(ok)
+		entry.Info("handling request")
+
+		tid, err := transactionidutils.GetTransactionIDFromContext(req.Context())
+		assert.NoError(err)
+		assert.Equal("tid_custom", tid)
+
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/content", nil)
+	req.Header.Set("X-Request-Id", "tid_custom")
+	TransactionAwareRequestLoggingHandler(log, inner).ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 2)
+
+	assert.Equal("handling request", entries[0]["msg"])
+	assert.Equal("tid_custom", entries[0]["transaction_id"])
+
+	assert.Equal("tid_publish", entries[1]["publish_reference"])
+	assert.Equal("methode", entries[1]["upstream_system"])
+	assert.Equal(float64(http.StatusOK), entries[1]["status"], "Custom fields should not override the handler fields")
+}
+
+func TestCustomLogFieldsNamedAsConditionalHandlerFields(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		AddLogField(req.Context(), "slow", true)
+		AddLogFields(req.Context(), map[string]interface{}{
+			"client_aborted":   true,
+			"write_error":      "broken pipe",
+			"headers":          "forged",
+			"response_headers": "forged",
+			"trailers":         "forged",
+			"request_body":     "forged",
+			"uuid":             "forged",
+		})
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/content", nil)
+	TransactionAwareRequestLoggingHandler(log, inner).ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal(float64(http.StatusOK), entries[0]["status"])
+	for _, key := range []string{"slow", "client_aborted", "write_error", "headers", "response_headers", "trailers", "request_body", "uuid"} {
+		assert.NotContains(entries[0], key, "Custom fields named as handler fields should be ignored")
+	}
+}
+
+func TestLogContextOutsideOfHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	AddLogField(context.Background(), "key", "value")
+	_, ok := LoggerFromContext(context.Background())
+	assert.False(ok)
+}
@@ -0,0 +1,118 @@
+package httphandlers
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+)
+
+// ExcludePaths creates a handler option that skips the request log for successful requests to the given paths,
+// e.g. the __gtg and __health probes. Failed and slow requests are still logged.
+func ExcludePaths(paths ...string) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		if h.excludedPaths == nil {
+			h.excludedPaths = map[string]bool{}
+		}
+		for _, p := range paths {
+			h.excludedPaths[p] = true
+		}
+	}
+}
+
+// SampleRequests creates a handler option that logs only the given fraction (between 0 and 1) of successful requests.
+// Failed and slow requests are always logged.
+func SampleRequests(rate float64) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.sampleRate = rate
+	}
+}
+
+// SampleRoute creates a handler option that logs at most perSecond successful requests per second
+// for the requests which path starts with prefix. Failed and slow requests are always logged.
+// When several prefixes match a request the first configured one is used.
+func SampleRoute(prefix string, perSecond float64) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.routeSamplers = append(h.routeSamplers, newRouteSampler(prefix, perSecond))
+	}
+}
+
+// LogLevelByStatus creates a handler option that logs 5xx responses at error level, 4xx responses at warn level
+// and everything else at info level.
+func LogLevelByStatus() handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.levelByStatus = true
+	}
+}
+
+// shouldLog decides whether the request log entry is written
+func (h transactionAwareRequestLoggingHandler) shouldLog(req *http.Request, status int, responseTime time.Duration) bool {
+	if status >= http.StatusBadRequest || h.isSlow(req, responseTime) {
+		return true
+	}
+	if h.excludedPaths[req.URL.Path] {
+		return false
+	}
+	for _, s := range h.routeSamplers {
+		if strings.HasPrefix(req.URL.Path, s.prefix) {
+			return s.allow(time.Now())
+		}
+	}
+	return h.sampleRate >= 1 || rand.Float64() < h.sampleRate
+}
+
+// writeEntry logs the final entry at the level matching the response status.
+// Slow requests are logged at least at warn level.
+func (h transactionAwareRequestLoggingHandler) writeEntry(entry *logger.LogEntry, status int, slow bool) {
+	switch {
+	case h.levelByStatus && status >= http.StatusInternalServerError:
+		entry.Error("")
+	case slow, h.levelByStatus && status >= http.StatusBadRequest:
+		entry.Warn("")
+	default:
+		entry.Info("")
+	}
+}
+
+// routeSampler is a token bucket limiting how many requests per second are logged for a route
+type routeSampler struct {
+	prefix    string
+	perSecond float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRouteSampler(prefix string, perSecond float64) *routeSampler {
+	return &routeSampler{prefix: prefix, perSecond: perSecond, tokens: burst(perSecond)}
+}
+
+func (s *routeSampler) allow(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.last.IsZero() {
+		s.tokens += now.Sub(s.last).Seconds() * s.perSecond
+		if b := burst(s.perSecond); s.tokens > b {
+			s.tokens = b
+		}
+	}
+	s.last = now
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// burst allows at least one entry for rates below one per second
+func burst(perSecond float64) float64 {
+	if perSecond < 1 {
+		return 1
+	}
+	return perSecond
+}
@@ -0,0 +1,156 @@
+package httphandlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// logEntries parses every line of buf as a JSON log entry
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &fields), "Could not unmarshall")
+		entries = append(entries, fields)
+	}
+	return entries
+}
+
+func TestRequestLogSampling(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		status        int
+		waitTime      time.Duration
+		options       []handlerOpt
+		requests      int
+		expectedLines int
+	}{
+		{
+			name:          "excluded path is not logged",
+			path:          "/__gtg",
+			status:        http.StatusOK,
+			options:       []handlerOpt{ExcludePaths("/__gtg", "/__health")},
+			requests:      3,
+			expectedLines: 0,
+		},
+		{
+			name:          "failed request to excluded path is logged",
+			path:          "/__gtg",
+			status:        http.StatusServiceUnavailable,
+			options:       []handlerOpt{ExcludePaths("/__gtg")},
+			requests:      3,
+			expectedLines: 3,
+		},
+		{
+			name:          "not excluded path is logged",
+			path:          "/content",
+			status:        http.StatusOK,
+			options:       []handlerOpt{ExcludePaths("/__gtg")},
+			requests:      3,
+			expectedLines: 3,
+		},
+		{
+			name:          "zero sample rate drops successful requests",
+			path:          "/content",
+			status:        http.StatusOK,
+			options:       []handlerOpt{SampleRequests(0)},
+			requests:      3,
+			expectedLines: 0,
+		},
+		{
+			name:          "zero sample rate keeps client errors",
+			path:          "/content",
+			status:        http.StatusNotFound,
+			options:       []handlerOpt{SampleRequests(0)},
+			requests:      3,
+			expectedLines: 3,
+		},
+		{
+			name:          "zero sample rate keeps slow requests",
+			path:          "/content",
+			status:        http.StatusOK,
+			waitTime:      20 * time.Millisecond,
+			options:       []handlerOpt{SampleRequests(0), SlowRequestThreshold(time.Millisecond)},
+			requests:      2,
+			expectedLines: 2,
+		},
+		{
+			name:          "route rate limit",
+			path:          "/content/123",
+			status:        http.StatusOK,
+			options:       []handlerOpt{SampleRoute("/content", 2)},
+			requests:      5,
+			expectedLines: 2,
+		},
+		{
+			name:          "route rate limit doesn't apply to other routes",
+			path:          "/lists/123",
+			status:        http.StatusOK,
+			options:       []handlerOpt{SampleRoute("/content", 1)},
+			requests:      3,
+			expectedLines: 3,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: test.status, WaitTime: test.waitTime}, test.options...)
+			for i := 0; i < test.requests; i++ {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.path, nil))
+			}
+			assert.Len(t, logEntries(t, buf), test.expectedLines)
+		})
+	}
+}
+
+func TestRouteSamplerRefills(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newRouteSampler("/content", 1)
+	now := time.Now()
+	assert.True(s.allow(now))
+	assert.False(s.allow(now))
+	assert.False(s.allow(now.Add(500 * time.Millisecond)))
+	assert.True(s.allow(now.Add(time.Second)))
+}
+
+func TestLogLevelByStatus(t *testing.T) {
+	tests := []struct {
+		status        int
+		expectedLevel string
+	}{
+		{status: http.StatusOK, expectedLevel: "info"},
+		{status: http.StatusFound, expectedLevel: "info"},
+		{status: http.StatusNotFound, expectedLevel: "warning"},
+		{status: http.StatusServiceUnavailable, expectedLevel: "error"},
+	}
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: test.status}, LogLevelByStatus())
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/content", nil))
+
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			assert.Equal(t, test.expectedLevel, entries[0]["level"])
+		})
+	}
+}
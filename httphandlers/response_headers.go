@@ -0,0 +1,46 @@
+package httphandlers
+
+import (
+	"regexp"
+)
+
+// DefaultResponseHeaderDenyList returns the response headers which are not logged by default.
+// Set-Cookie is denied as it carries session tokens, it can be logged with its values masked by RedactHeaderValues
+// after replacing the deny list.
+// A new slice is returned on every call so it can be modified safely.
+func DefaultResponseHeaderDenyList() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile("(?i:^Set-Cookie$)"),
+		regexp.MustCompile("(?i:^X-Request-Id$)"),
+		regexp.MustCompile("(?i:^Connection$)"),
+		regexp.MustCompile("(?i:^Content-Length$)"),
+		regexp.MustCompile("(?i:^Transfer-Encoding$)"),
+		regexp.MustCompile("(?i:^Date$)"),
+	}
+}
+
+// LogResponseHeaders creates a handler option that adds the response headers to the log entry as response_headers.
+// Response headers have their own deny list. The optional callback filters the headers further in the same way as
+// the one passed to FilterHeaders. Header values are redacted with the rules set by RedactHeaderValues.
+func LogResponseHeaders(fn HeaderFilter) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.responseHeaderFilter().fn = fn
+	}
+}
+
+// AllowResponseHeaders creates a handler option that adds only the named response headers to the log entry
+// as response_headers, e.g. Cache-Control, Surrogate-Key, Content-Type and ETag.
+func AllowResponseHeaders(names ...string) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		f := h.responseHeaderFilter()
+		f.allow = append(f.allow, headerNamesRegexps(names)...)
+	}
+}
+
+// responseHeaderFilter enables the response headers logging and returns its filter
+func (h *transactionAwareRequestLoggingHandler) responseHeaderFilter() *headerFilter {
+	if h.responseHeaders == nil {
+		h.responseHeaders = &headerFilter{deny: DefaultResponseHeaderDenyList()}
+	}
+	return h.responseHeaders
+}
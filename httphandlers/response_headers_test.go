@@ -0,0 +1,80 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseHeadersLogging(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []handlerOpt
+		expected interface{}
+	}{
+		{
+			name:     "not logged by default",
+			expected: nil,
+		},
+		{
+			name:    "deny list",
+			options: []handlerOpt{LogResponseHeaders(nil)},
+			expected: map[string]interface{}{
+				"Cache-Control": "max-age=60",
+				"Content-Type":  "application/json",
+				"Etag":          `"abc"`,
+				"Surrogate-Key": "content list",
+			},
+		},
+		{
+			name: "deny list with filter and redaction",
+			options: []handlerOpt{
+				LogResponseHeaders(func(key string) bool { return key != "Etag" }),
+				ReplaceResponseHeaderDenyList(regexp.MustCompile("(?i:^X-Request-Id$)"), regexp.MustCompile("(?i:^Content-Length$)")),
+				RedactHeaderValues(HeaderRedaction{}),
+			},
+			expected: map[string]interface{}{
+				"Cache-Control": "max-age=60",
+				"Content-Type":  "application/json",
+				"Surrogate-Key": "content list",
+				"Set-Cookie":    "session=[REDACTED]; Path=/",
+			},
+		},
+		{
+			name:    "allow list",
+			options: []handlerOpt{AllowResponseHeaders("cache-control", "Surrogate-Key")},
+			expected: map[string]interface{}{
+				"Cache-Control": "max-age=60",
+				"Surrogate-Key": "content list",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"abc"`)
+				w.Header().Set("Surrogate-Key", "content list")
+				w.Header().Set("Set-Cookie", "session=secret; Path=/")
+				w.Header().Set("Content-Length", "2")
+				_, _ = w.Write([]byte("{}"))
+			})
+			handler := TransactionAwareRequestLoggingHandler(log, inner, test.options...)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/content", nil))
+
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			assert.Equal(t, test.expected, entries[0]["response_headers"])
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package httphandlers
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// optional interfaces of http.ResponseWriter the logging writer forwards
+const (
+	flusher = 1 << iota
+	hijacker
+	closeNotifier
+	readerFrom
+	pusher
+)
+
+// wrapWriter wraps w in a loggingResponseWriter implementing exactly the optional interfaces w implements.
+// Other features, e.g. deadlines and full duplex, are reachable through http.ResponseController as the wrapper
+// implements Unwrap.
+func wrapWriter(w http.ResponseWriter) loggingResponseWriter {
+	l := &responseLogger{w: w}
+
+	features := 0
+	if _, ok := w.(http.Flusher); ok {
+		features |= flusher
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		features |= hijacker
+	}
+	if _, ok := w.(http.CloseNotifier); ok {
+		features |= closeNotifier
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		features |= readerFrom
+	}
+	if _, ok := w.(http.Pusher); ok {
+		features |= pusher
+	}
+
+	switch features {
+	case 0:
+		return struct {
+			loggingResponseWriter
+		}{l}
+	case flusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+		}{l, l}
+	case hijacker:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+		}{l, l}
+	case flusher | hijacker:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{l, l, l}
+	case closeNotifier:
+		return struct {
+			loggingResponseWriter
+			http.CloseNotifier
+		}{l, l}
+	case flusher | closeNotifier:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.CloseNotifier
+		}{l, l, l}
+	case hijacker | closeNotifier:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			http.CloseNotifier
+		}{l, l, l}
+	case flusher | hijacker | closeNotifier:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+		}{l, l, l, l}
+	case readerFrom:
+		return struct {
+			loggingResponseWriter
+			io.ReaderFrom
+		}{l, l}
+	case flusher | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{l, l, l}
+	case hijacker | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{l, l, l}
+	case flusher | hijacker | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{l, l, l, l}
+	case closeNotifier | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.CloseNotifier
+			io.ReaderFrom
+		}{l, l, l}
+	case flusher | closeNotifier | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.CloseNotifier
+			io.ReaderFrom
+		}{l, l, l, l}
+	case hijacker | closeNotifier | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+		}{l, l, l, l}
+	case flusher | hijacker | closeNotifier | readerFrom:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+		}{l, l, l, l, l}
+	case pusher:
+		return struct {
+			loggingResponseWriter
+			http.Pusher
+		}{l, l}
+	case flusher | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Pusher
+		}{l, l, l}
+	case hijacker | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			http.Pusher
+		}{l, l, l}
+	case flusher | hijacker | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{l, l, l, l}
+	case closeNotifier | pusher:
+		return struct {
+			loggingResponseWriter
+			http.CloseNotifier
+			http.Pusher
+		}{l, l, l}
+	case flusher | closeNotifier | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.CloseNotifier
+			http.Pusher
+		}{l, l, l, l}
+	case hijacker | closeNotifier | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			http.CloseNotifier
+			http.Pusher
+		}{l, l, l, l}
+	case flusher | hijacker | closeNotifier | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			http.Pusher
+		}{l, l, l, l, l}
+	case readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l}
+	case flusher | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l}
+	case hijacker | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l}
+	case flusher | hijacker | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l, l}
+	case closeNotifier | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l}
+	case flusher | closeNotifier | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l, l}
+	case hijacker | closeNotifier | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l, l}
+	case flusher | hijacker | closeNotifier | readerFrom | pusher:
+		return struct {
+			loggingResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			io.ReaderFrom
+			http.Pusher
+		}{l, l, l, l, l, l}
+	}
+	return struct {
+		loggingResponseWriter
+	}{l}
+}
+
+type loggingResponseWriter interface {
+	http.ResponseWriter
+	Status() int
+	Size() int
+	Unwrap() http.ResponseWriter
+	captureBody(limit int)
+	capturedBody() *bodyBuffer
+	informational() []int
+	earlyHints() []string
+	trailers() http.Header
+	writeError() error
+	attemptedSize() int
+	onHijack(hook func(net.Conn, *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter))
+}
+
+// responseLogger is wrapper of http.ResponseWriter that keeps track of its HTTP
+// status code and body size
+type responseLogger struct {
+	w       http.ResponseWriter
+	status  int
+	size    int
+	body    *bodyBuffer
+	interim []int
+	hints   []string
+	// attempted is the number of bytes the handler tried to write, size the number of bytes written successfully
+	attempted int
+	writeErr  error
+	// hijackHook can replace the hijacked connection, e.g. to track it
+	hijackHook func(net.Conn, *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter)
+}
+
+func (l *responseLogger) Header() http.Header {
+	return l.w.Header()
+}
+
+func (l *responseLogger) Write(b []byte) (int, error) {
+	if l.status == 0 {
+		// The status will be StatusOK if WriteHeader has not been called yet
+		l.status = http.StatusOK
+	}
+	size, err := l.w.Write(b)
+	l.size += size
+	l.attempted += len(b)
+	l.recordWriteError(err)
+	if l.body != nil {
+		l.body.write(b[:size])
+	}
+	return size, err
+}
+
+func (l *responseLogger) WriteHeader(s int) {
+	l.w.WriteHeader(s)
+	if l.status != 0 {
+		// the final status has already been sent, net/http ignores the superfluous call
+		return
+	}
+	if s >= 100 && s <= 199 && s != http.StatusSwitchingProtocols {
+		// informational responses can be sent any number of times before the final one
+		l.interim = append(l.interim, s)
+		if s == http.StatusEarlyHints {
+			l.hints = append(l.hints, l.w.Header().Values("Link")...)
+		}
+		return
+	}
+	l.status = s
+}
+
+func (l *responseLogger) Status() int {
+	return l.status
+}
+
+func (l *responseLogger) Size() int {
+	return l.size
+}
+
+// Unwrap returns the wrapped http.ResponseWriter for http.ResponseController
+func (l *responseLogger) Unwrap() http.ResponseWriter {
+	return l.w
+}
+
+// captureBody starts keeping the first limit bytes of the response body
+func (l *responseLogger) captureBody(limit int) {
+	l.body = &bodyBuffer{limit: limit}
+}
+
+func (l *responseLogger) capturedBody() *bodyBuffer {
+	return l.body
+}
+
+// recordWriteError keeps the first error returned by the wrapped writer
+func (l *responseLogger) recordWriteError(err error) {
+	if err != nil && l.writeErr == nil {
+		l.writeErr = err
+	}
+}
+
+func (l *responseLogger) writeError() error {
+	return l.writeErr
+}
+
+func (l *responseLogger) attemptedSize() int {
+	return l.attempted
+}
+
+// informational returns the 1xx statuses sent before the final response
+func (l *responseLogger) informational() []int {
+	return l.interim
+}
+
+// earlyHints returns the Link header values sent with 103 Early Hints responses
+func (l *responseLogger) earlyHints() []string {
+	return l.hints
+}
+
+// trailers returns the trailers set by the handler, both the ones declared in the Trailer header
+// and the ones set with the http.TrailerPrefix
+func (l *responseLogger) trailers() http.Header {
+	header := l.w.Header()
+	trailers := http.Header{}
+	for _, declared := range header.Values("Trailer") {
+		for _, key := range strings.Split(declared, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if val, ok := header[key]; ok {
+				trailers[key] = val
+			}
+		}
+	}
+	for key, val := range header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			trailers[http.CanonicalHeaderKey(name)] = val
+		}
+	}
+	return trailers
+}
+
+func (l *responseLogger) Flush() {
+	if l.status == 0 {
+		// Flushing sends the headers with StatusOK if WriteHeader has not been called yet
+		l.status = http.StatusOK
+	}
+	l.w.(http.Flusher).Flush()
+}
+
+func (l *responseLogger) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := l.w.(http.Hijacker).Hijack()
+	if err == nil && l.status == 0 {
+		// The status will be StatusSwitchingProtocols if there was no error and
+		// WriteHeader has not been called yet
+		l.status = http.StatusSwitchingProtocols
+	}
+	if err == nil && l.hijackHook != nil {
+		conn, rw = l.hijackHook(conn, rw)
+	}
+	return conn, rw, err
+}
+
+func (l *responseLogger) onHijack(hook func(net.Conn, *bufio.ReadWriter) (net.Conn, *bufio.ReadWriter)) {
+	l.hijackHook = hook
+}
+
+func (l *responseLogger) CloseNotify() <-chan bool {
+	return l.w.(http.CloseNotifier).CloseNotify() // nolint:staticcheck // forwarded for handlers still relying on it
+}
+
+// ReadFrom lets the wrapped writer use sendfile when copying files to the response
+func (l *responseLogger) ReadFrom(r io.Reader) (int64, error) {
+	if l.body != nil {
+		// the body is captured so it has to go through Write
+		return io.Copy(writerOnly{l}, r)
+	}
+	if l.status == 0 {
+		l.status = http.StatusOK
+	}
+	n, err := l.w.(io.ReaderFrom).ReadFrom(r)
+	l.size += int(n)
+	l.attempted += int(n)
+	l.recordWriteError(err)
+	return n, err
+}
+
+func (l *responseLogger) Push(target string, opts *http.PushOptions) error {
+	return l.w.(http.Pusher).Push(target, opts)
+}
+
+// writerOnly hides the optional interfaces of the writer, so io.Copy doesn't call ReadFrom recursively
+type writerOnly struct {
+	io.Writer
+}
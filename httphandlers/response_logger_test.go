@@ -0,0 +1,264 @@
+package httphandlers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// plainWriter implements only http.ResponseWriter
+type plainWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (w *plainWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *plainWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *plainWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// pushWriter implements http.ResponseWriter, io.ReaderFrom and http.Pusher
+type pushWriter struct {
+	plainWriter
+	pushed   []string
+	readFrom bool
+}
+
+func (w *pushWriter) Push(target string, _ *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func (w *pushWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return w.body.ReadFrom(r)
+}
+
+func TestWrapWriterInterfaces(t *testing.T) {
+	tests := []struct {
+		name                  string
+		writer                http.ResponseWriter
+		expectedFlusher       bool
+		expectedHijacker      bool
+		expectedCloseNotifier bool
+		expectedReaderFrom    bool
+		expectedPusher        bool
+	}{
+		{
+			name:   "plain writer",
+			writer: &plainWriter{},
+		},
+		{
+			name:            "recorder",
+			writer:          httptest.NewRecorder(),
+			expectedFlusher: true,
+		},
+		{
+			name:               "pusher and reader from",
+			writer:             &pushWriter{},
+			expectedReaderFrom: true,
+			expectedPusher:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := wrapWriter(test.writer)
+			_, ok := w.(http.Flusher)
+			assert.Equal(t, test.expectedFlusher, ok, "Flusher")
+			_, ok = w.(http.Hijacker)
+			assert.Equal(t, test.expectedHijacker, ok, "Hijacker")
+			_, ok = w.(http.CloseNotifier)
+			assert.Equal(t, test.expectedCloseNotifier, ok, "CloseNotifier")
+			_, ok = w.(io.ReaderFrom)
+			assert.Equal(t, test.expectedReaderFrom, ok, "ReaderFrom")
+			_, ok = w.(http.Pusher)
+			assert.Equal(t, test.expectedPusher, ok, "Pusher")
+			assert.Equal(t, test.writer, w.Unwrap())
+		})
+	}
+}
+
+func TestWrapWriterServerInterfaces(t *testing.T) {
+	assert := assert.New(t)
+
+	type features struct {
+		flusher, hijacker, closeNotifier, readerFrom bool
+		deadlineErr                                  error
+	}
+	result := make(chan features, 1)
+	log := logger.NewUPPInfoLogger("test-service")
+	log.Out = new(bytes.Buffer)
+
+	handler := TransactionAwareRequestLoggingHandler(log, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var f features
+		_, f.flusher = w.(http.Flusher)
+		_, f.hijacker = w.(http.Hijacker)
+		_, f.closeNotifier = w.(http.CloseNotifier)
+		_, f.readerFrom = w.(io.ReaderFrom)
+		f.deadlineErr = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(time.Minute))
+		result <- f
+	}))
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	assert.NoError(err)
+	resp.Body.Close()
+
+	f := <-result
+	assert.True(f.flusher)
+	assert.True(f.hijacker)
+	assert.True(f.closeNotifier)
+	assert.True(f.readerFrom)
+	assert.NoError(f.deadlineErr, "Deadlines should be reachable through http.ResponseController")
+}
+
+func TestResponseLoggerForwarding(t *testing.T) {
+	assert := assert.New(t)
+
+	pw := &pushWriter{}
+	w := wrapWriter(pw)
+
+	n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	assert.NoError(err)
+	assert.Equal(int64(5), n)
+	assert.True(pw.readFrom, "ReadFrom should be forwarded to the wrapped writer")
+	assert.Equal(5, w.Size())
+	assert.Equal(http.StatusOK, w.Status())
+
+	assert.NoError(w.(http.Pusher).Push("/style.css", nil))
+	assert.Equal([]string{"/style.css"}, pw.pushed)
+}
+
+func TestResponseLoggerReadFromWithBodyCapture(t *testing.T) {
+	assert := assert.New(t)
+
+	pw := &pushWriter{}
+	w := wrapWriter(pw)
+	w.captureBody(3)
+
+	n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	assert.NoError(err)
+	assert.Equal(int64(5), n)
+	assert.False(pw.readFrom, "Captured body should go through Write")
+	assert.Equal("hello", pw.body.String())
+	assert.Equal("hel", string(w.capturedBody().data))
+}
+
+// hijackWriter implements http.ResponseWriter and http.Hijacker
+type hijackWriter struct {
+	plainWriter
+}
+
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestResponseLoggerHijack(t *testing.T) {
+	assert := assert.New(t)
+
+	w := wrapWriter(&hijackWriter{})
+	_, ok := w.(http.Flusher)
+	assert.False(ok)
+
+	conn, _, err := w.(http.Hijacker).Hijack()
+	assert.NoError(err)
+	conn.Close()
+	assert.Equal(http.StatusSwitchingProtocols, w.Status())
+}
+
+func TestResponseLoggerInformationalResponses(t *testing.T) {
+	assert := assert.New(t)
+
+	pw := &plainWriter{}
+	w := wrapWriter(pw)
+
+	w.WriteHeader(http.StatusContinue)
+	w.Header().Add("Link", "</style.css>; rel=preload; as=style")
+	w.WriteHeader(http.StatusEarlyHints)
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusInternalServerError)
+
+	assert.Equal(http.StatusCreated, w.Status(), "The first final status should be reported")
+	assert.Equal([]int{http.StatusContinue, http.StatusEarlyHints}, w.informational())
+	assert.Equal([]string{"</style.css>; rel=preload; as=style"}, w.earlyHints())
+}
+
+func TestResponseLoggerTrailers(t *testing.T) {
+	assert := assert.New(t)
+
+	w := wrapWriter(&plainWriter{})
+	w.Header().Set("Trailer", "Grpc-Status, grpc-message")
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Grpc-Status", "0")
+	w.Header().Set("Grpc-Message", "ok")
+	w.Header().Set(http.TrailerPrefix+"X-Checksum", "abc")
+
+	assert.Equal(http.Header{
+		"Grpc-Status":  {"0"},
+		"Grpc-Message": {"ok"},
+		"X-Checksum":   {"abc"},
+	}, w.trailers())
+}
+
+func TestLogInformationalResponsesAndTrailers(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Link", "</app.js>; rel=preload; as=script")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("accepted"))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	handler := TransactionAwareRequestLoggingHandler(log, inner, LogResponseHeaders(nil))
+	ts := httptest.NewServer(handler)
+
+	resp, err := http.Get(ts.URL + "/content")
+	assert.NoError(err)
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(http.StatusAccepted, resp.StatusCode)
+	assert.Equal("0", resp.Trailer.Get("Grpc-Status"))
+	ts.Close()
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 1)
+	assert.Equal(float64(http.StatusAccepted), entries[0]["status"])
+	assert.Equal([]interface{}{float64(http.StatusEarlyHints)}, entries[0]["informational"])
+	assert.Equal([]interface{}{"</app.js>; rel=preload; as=script"}, entries[0]["early_hints"])
+	assert.Equal(map[string]interface{}{"Grpc-Status": "0"}, entries[0]["trailers"])
+	responseHeaders, ok := entries[0]["response_headers"].(map[string]interface{})
+	assert.True(ok)
+	assert.NotContains(responseHeaders, "Grpc-Status")
+	assert.Equal("text/plain", responseHeaders["Content-Type"])
+}
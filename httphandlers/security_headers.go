@@ -0,0 +1,170 @@
+package httphandlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSPNoncePlaceholder is replaced in SecurityHeaders.ContentSecurityPolicy by a nonce generated for each request.
+const CSPNoncePlaceholder = "{nonce}"
+
+// SecurityHeaders are the security response headers set by SecurityHeadersHandler. Empty values are not set.
+type SecurityHeaders struct {
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header, which is not set if it is 0.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubDomains bool
+	HSTSPreload           bool
+	// NoSniff sets X-Content-Type-Options to nosniff.
+	NoSniff           bool
+	FrameOptions      string
+	ReferrerPolicy    string
+	PermissionsPolicy string
+	// ContentSecurityPolicy is the Content-Security-Policy header. Occurrences of CSPNoncePlaceholder are replaced by
+	// a nonce generated for each request, which the handlers get with CSPNonceFromContext.
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only instead.
+	CSPReportOnly bool
+}
+
+// JSONAPISecurityHeaders returns the security headers for services responding with JSON only.
+func JSONAPISecurityHeaders() SecurityHeaders {
+	return SecurityHeaders{
+		HSTSMaxAge:            2 * 365 * 24 * time.Hour,
+		HSTSIncludeSubDomains: true,
+		NoSniff:               true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		ContentSecurityPolicy: "default-src 'none'; frame-ancestors 'none'",
+	}
+}
+
+// HTMLPageSecurityHeaders returns the security headers for services serving HTML pages.
+// Inline scripts and styles are allowed only with the nonce of the request, see CSPNonceFromContext.
+func HTMLPageSecurityHeaders() SecurityHeaders {
+	return SecurityHeaders{
+		HSTSMaxAge:            2 * 365 * 24 * time.Hour,
+		HSTSIncludeSubDomains: true,
+		NoSniff:               true,
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		PermissionsPolicy:     "camera=(), microphone=(), geolocation=(), payment=()",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-" + CSPNoncePlaceholder + "'; " +
+			"style-src 'self' 'nonce-" + CSPNoncePlaceholder + "'; object-src 'none'; base-uri 'self'; frame-ancestors 'self'",
+	}
+}
+
+type securityHeadersOpt func(h *securityHeadersHandler)
+
+// SecurityHeadersForRoute creates an option that uses other security headers for the requests whose path starts with prefix.
+// The longest matching prefix wins.
+func SecurityHeadersForRoute(prefix string, config SecurityHeaders) securityHeadersOpt { // nolint:golint // we don't want securityHeadersOpt exported
+	return func(h *securityHeadersHandler) {
+		h.routes = append(h.routes, securityHeadersRoute{prefix: prefix, headers: config.header()})
+	}
+}
+
+// SecurityHeadersHandler sets the security response headers before calling the inner handler,
+// which can still override them.
+func SecurityHeadersHandler(config SecurityHeaders, h http.Handler, options ...securityHeadersOpt) http.Handler {
+	handler := &securityHeadersHandler{handler: h, headers: config.header()}
+	for _, opt := range options {
+		opt(handler)
+	}
+	return handler
+}
+
+type securityHeadersRoute struct {
+	prefix  string
+	headers securityHeaderValues
+}
+
+// securityHeaderValues are the precomputed header values of a SecurityHeaders
+type securityHeaderValues struct {
+	values    http.Header
+	cspHeader string
+	csp       string
+}
+
+func (c SecurityHeaders) header() securityHeaderValues {
+	values := http.Header{}
+	if c.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(c.HSTSMaxAge.Seconds()), 10)
+		if c.HSTSIncludeSubDomains {
+			hsts += "; includeSubDomains"
+		}
+		if c.HSTSPreload {
+			hsts += "; preload"
+		}
+		values.Set("Strict-Transport-Security", hsts)
+	}
+	if c.NoSniff {
+		values.Set("X-Content-Type-Options", "nosniff")
+	}
+	if c.FrameOptions != "" {
+		values.Set("X-Frame-Options", c.FrameOptions)
+	}
+	if c.ReferrerPolicy != "" {
+		values.Set("Referrer-Policy", c.ReferrerPolicy)
+	}
+	if c.PermissionsPolicy != "" {
+		values.Set("Permissions-Policy", c.PermissionsPolicy)
+	}
+	cspHeader := "Content-Security-Policy"
+	if c.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	return securityHeaderValues{values: values, cspHeader: cspHeader, csp: c.ContentSecurityPolicy}
+}
+
+type securityHeadersHandler struct {
+	handler http.Handler
+	headers securityHeaderValues
+	routes  []securityHeadersRoute
+}
+
+type cspNonceKey struct{}
+
+// CSPNonceFromContext returns the nonce of the Content-Security-Policy set by SecurityHeadersHandler for the request
+// the context belongs to. It returns false if the policy of the request has no nonce.
+func CSPNonceFromContext(ctx context.Context) (string, bool) {
+	nonce, ok := ctx.Value(cspNonceKey{}).(string)
+	return nonce, ok
+}
+
+func (h *securityHeadersHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	headers := h.headers
+	matched := -1
+	for _, route := range h.routes {
+		if strings.HasPrefix(req.URL.Path, route.prefix) && len(route.prefix) > matched {
+			headers = route.headers
+			matched = len(route.prefix)
+		}
+	}
+
+	header := w.Header()
+	for key, val := range headers.values {
+		header[key] = append([]string(nil), val...)
+	}
+	if headers.csp != "" {
+		csp := headers.csp
+		if strings.Contains(csp, CSPNoncePlaceholder) {
+			nonce := newCSPNonce()
+			csp = strings.ReplaceAll(csp, CSPNoncePlaceholder, nonce)
+			req = req.WithContext(context.WithValue(req.Context(), cspNonceKey{}, nonce))
+		}
+		header.Set(headers.cspHeader, csp)
+	}
+	h.handler.ServeHTTP(w, req)
+}
+
+// newCSPNonce returns a base64 encoded 128 bits random nonce
+func newCSPNonce() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return base64.StdEncoding.EncodeToString(b[:])
+}
@@ -0,0 +1,105 @@
+package httphandlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityHeadersHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		expectedHeaders map[string]string
+		expectedAbsent  []string
+		expectedNonce   bool
+	}{
+		{
+			name: "json api",
+			path: "/content",
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=63072000; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "no-referrer",
+				"Content-Security-Policy":   "default-src 'none'; frame-ancestors 'none'",
+			},
+			expectedAbsent: []string{"Permissions-Policy"},
+		},
+		{
+			name: "html pages",
+			path: "/docs/index.html",
+			expectedHeaders: map[string]string{
+				"X-Frame-Options":    "SAMEORIGIN",
+				"Referrer-Policy":    "strict-origin-when-cross-origin",
+				"Permissions-Policy": "camera=(), microphone=(), geolocation=(), payment=()",
+			},
+			expectedNonce: true,
+		},
+		{
+			name: "longest prefix",
+			path: "/docs/embed/widget",
+			expectedHeaders: map[string]string{
+				"Strict-Transport-Security":           "max-age=3600; preload",
+				"Content-Security-Policy-Report-Only": "frame-ancestors *",
+			},
+			expectedAbsent: []string{"X-Frame-Options", "X-Content-Type-Options", "Content-Security-Policy"},
+		},
+	}
+	embed := SecurityHeaders{
+		HSTSMaxAge:            time.Hour,
+		HSTSPreload:           true,
+		ContentSecurityPolicy: "frame-ancestors *",
+		CSPReportOnly:         true,
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var nonce string
+			var hasNonce bool
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				nonce, hasNonce = CSPNonceFromContext(req.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+			handler := SecurityHeadersHandler(JSONAPISecurityHeaders(), inner,
+				SecurityHeadersForRoute("/docs/embed", embed),
+				SecurityHeadersForRoute("/docs", HTMLPageSecurityHeaders()),
+			)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+
+			for key, value := range test.expectedHeaders {
+				assert.Equal(value, w.Header().Get(key), key)
+			}
+			for _, key := range test.expectedAbsent {
+				assert.Empty(w.Header().Get(key), key)
+			}
+			assert.Equal(test.expectedNonce, hasNonce)
+			if test.expectedNonce {
+				assert.Len(nonce, 24)
+				assert.Contains(w.Header().Get("Content-Security-Policy"), "script-src 'self' 'nonce-"+nonce+"'")
+				assert.NotContains(w.Header().Get("Content-Security-Policy"), CSPNoncePlaceholder)
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersNoncePerRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := SecurityHeadersHandler(HTMLPageSecurityHeaders(), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Frame-Options", "DENY")
+	}))
+
+	first, second := httptest.NewRecorder(), httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest("GET", "/", nil))
+	handler.ServeHTTP(second, httptest.NewRequest("GET", "/", nil))
+
+	assert.NotEqual(first.Header().Get("Content-Security-Policy"), second.Header().Get("Content-Security-Policy"))
+	assert.Equal("DENY", first.Header().Get("X-Frame-Options"), "The inner handler should be able to override the headers")
+}
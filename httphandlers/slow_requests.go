@@ -0,0 +1,78 @@
+package httphandlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// SlowRequestThreshold creates a handler option that marks requests taking longer than d as slow.
+// Slow requests are always logged regardless of the sampling options, at warn level and with a slow field.
+func SlowRequestThreshold(d time.Duration) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.slowThreshold = d
+	}
+}
+
+// SlowRouteThreshold creates a handler option overriding the slow request threshold for the requests
+// which path starts with prefix. When several prefixes match a request the first configured one is used.
+func SlowRouteThreshold(prefix string, d time.Duration) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.slowRoutes = append(h.slowRoutes, slowRoute{prefix: prefix, threshold: d})
+	}
+}
+
+// InFlightWatchdog creates a handler option that logs a warning with the transaction ID for every request
+// that is still being handled after d, without waiting for it to complete.
+func InFlightWatchdog(d time.Duration) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.watchdogThreshold = d
+	}
+}
+
+// RecordMetrics creates a handler option that counts notable requests, e.g. the slow ones, in the registry.
+func RecordMetrics(registry metrics.Registry) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.registry = registry
+	}
+}
+
+type slowRoute struct {
+	prefix    string
+	threshold time.Duration
+}
+
+func (h transactionAwareRequestLoggingHandler) isSlow(req *http.Request, responseTime time.Duration) bool {
+	threshold := h.slowThreshold
+	for _, r := range h.slowRoutes {
+		if strings.HasPrefix(req.URL.Path, r.prefix) {
+			threshold = r.threshold
+			break
+		}
+	}
+	return threshold > 0 && responseTime > threshold
+}
+
+// countSlowRequest increments the slow requests counter if a registry was provided
+func (h transactionAwareRequestLoggingHandler) countSlowRequest() {
+	if h.registry != nil {
+		metrics.GetOrRegisterCounter("slow_requests", h.registry).Inc(1)
+	}
+}
+
+// startWatchdog schedules the still running log entry for the request.
+// The returned function must be called once the request has been handled.
+func (h transactionAwareRequestLoggingHandler) startWatchdog(req *http.Request, transactionID string, start time.Time) func() bool {
+	if h.watchdogThreshold <= 0 {
+		return func() bool { return false }
+	}
+	// read the fields now so the timer doesn't read the request while the handler uses it
+	fields := h.requestFields(req, transactionID)
+	timer := time.AfterFunc(h.watchdogThreshold, func() {
+		fields["elapsed"] = int64(time.Since(start).Seconds() * 1000)
+		h.logEntry(fields).Warn("request still running")
+	})
+	return timer.Stop
+}
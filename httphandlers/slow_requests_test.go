@@ -0,0 +1,97 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		waitTime     time.Duration
+		options      []handlerOpt
+		expectedSlow bool
+	}{
+		{
+			name:         "request over the threshold",
+			path:         "/content",
+			waitTime:     20 * time.Millisecond,
+			options:      []handlerOpt{SlowRequestThreshold(time.Millisecond)},
+			expectedSlow: true,
+		},
+		{
+			name:         "request under the threshold",
+			path:         "/content",
+			options:      []handlerOpt{SlowRequestThreshold(time.Second)},
+			expectedSlow: false,
+		},
+		{
+			name:         "route threshold overrides the global one",
+			path:         "/content/export",
+			waitTime:     20 * time.Millisecond,
+			options:      []handlerOpt{SlowRequestThreshold(time.Millisecond), SlowRouteThreshold("/content/export", time.Second)},
+			expectedSlow: false,
+		},
+		{
+			name:         "route threshold without global one",
+			path:         "/content",
+			waitTime:     20 * time.Millisecond,
+			options:      []handlerOpt{SlowRouteThreshold("/content", time.Millisecond)},
+			expectedSlow: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+			r := metrics.NewRegistry()
+
+			options := append(test.options, RecordMetrics(r))
+			handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK, WaitTime: test.waitTime}, options...)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.path, nil))
+
+			entries := logEntries(t, buf)
+			assert.Len(t, entries, 1)
+			if test.expectedSlow {
+				assert.Equal(t, true, entries[0]["slow"])
+				assert.Equal(t, "warning", entries[0]["level"])
+				assert.Equal(t, int64(1), metrics.GetOrRegisterCounter("slow_requests", r).Count())
+			} else {
+				assert.NotContains(t, entries[0], "slow")
+				assert.Equal(t, "info", entries[0]["level"])
+				assert.Equal(t, int64(0), metrics.GetOrRegisterCounter("slow_requests", r).Count())
+			}
+		})
+	}
+}
+
+func TestInFlightWatchdog(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK, WaitTime: 50 * time.Millisecond}, InFlightWatchdog(10*time.Millisecond))
+	req := httptest.NewRequest("GET", "/content", nil)
+	req.Header.Set("X-Request-Id", "tid_watchdog")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	assert.Len(entries, 2)
+	assert.Equal("request still running", entries[0]["msg"])
+	assert.Equal("tid_watchdog", entries[0]["transaction_id"])
+	assert.Equal("/content", entries[0]["uri"])
+	assert.Equal("tid_watchdog", entries[1]["transaction_id"])
+}
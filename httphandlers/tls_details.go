@@ -0,0 +1,86 @@
+package httphandlers
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// LogTLSDetails creates a handler option that adds the details of the TLS connection of the request to the log entry:
+// TLS version, cipher suite, SNI server name, negotiated protocol, session resumption and the client certificate
+// subject, issuer and serial number when one was presented.
+func LogTLSDetails() handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.tlsDetails = true
+	}
+}
+
+// connectionIDs generates the IDs of the connections tracked by TrackConnections
+var connectionIDs atomic.Uint64
+
+// trackedConnection counts the requests received on a connection
+type trackedConnection struct {
+	id       uint64
+	requests atomic.Int64
+}
+
+type connectionContextKey int
+
+const (
+	// trackedConnectionKey is the key used to store the tracked connection on the connection context
+	trackedConnectionKey connectionContextKey = iota
+	// connectionRequestKey is the key used to store the position of the request on its connection
+	connectionRequestKey
+)
+
+// TrackConnections is meant to be used as the ConnContext of an http.Server. It makes
+// TransactionAwareRequestLoggingHandler log the connection_id of the request and how many requests
+// the connection received so far in connection_requests, requests on reused connections are logged with connection_reused.
+func TrackConnections(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, trackedConnectionKey, &trackedConnection{id: connectionIDs.Add(1)})
+}
+
+// connectionRequest is the position of a request on its tracked connection
+type connectionRequest struct {
+	connectionID uint64
+	sequence     int64
+}
+
+// withConnectionRequest counts the request on its connection if the connection is tracked
+func withConnectionRequest(ctx context.Context) context.Context {
+	conn, ok := ctx.Value(trackedConnectionKey).(*trackedConnection)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, connectionRequestKey, connectionRequest{
+		connectionID: conn.id,
+		sequence:     conn.requests.Add(1),
+	})
+}
+
+// addConnectionFields adds the connection and TLS details of the request to fields
+func (h transactionAwareRequestLoggingHandler) addConnectionFields(fields map[string]interface{}, req *http.Request) {
+	if c, ok := req.Context().Value(connectionRequestKey).(connectionRequest); ok {
+		fields["connection_id"] = c.connectionID
+		fields["connection_requests"] = c.sequence
+		fields["connection_reused"] = c.sequence > 1
+	}
+
+	if !h.tlsDetails || req.TLS == nil {
+		return
+	}
+	state := req.TLS
+	fields["tls_version"] = tls.VersionName(state.Version)
+	fields["tls_cipher"] = tls.CipherSuiteName(state.CipherSuite)
+	fields["tls_server_name"] = state.ServerName
+	fields["tls_alpn"] = state.NegotiatedProtocol
+	fields["tls_resumed"] = state.DidResume
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		fields["client_cert_subject"] = cert.Subject.String()
+		fields["client_cert_issuer"] = cert.Issuer.String()
+		fields["client_cert_serial"] = cert.SerialNumber.String()
+	}
+}
@@ -0,0 +1,108 @@
+package httphandlers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func clientCertificate(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "test-client", Organization: []string{"FT"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestLogTLSDetails(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ts := httptest.NewUnstartedServer(TransactionAwareRequestLoggingHandler(log, inner, LogTLSDetails()))
+	ts.EnableHTTP2 = true
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.Config.ConnContext = TrackConnections
+	ts.StartTLS()
+
+	client := ts.Client()
+	transport := client.Transport.(*http.Transport)
+	transport.TLSClientConfig.Certificates = []tls.Certificate{clientCertificate(t)}
+	transport.TLSClientConfig.ServerName = "example.com"
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL + "/content")
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Equal("HTTP/2.0", resp.Proto)
+	}
+	ts.Close()
+
+	entries := logEntries(t, buf)
+	if !assert.Len(entries, 2) {
+		return
+	}
+	for i, entry := range entries {
+		assert.Equal("HTTP/2.0", entry["protocol"])
+		assert.Equal("TLS 1.3", entry["tls_version"])
+		assert.NotEmpty(entry["tls_cipher"])
+		assert.Equal("example.com", entry["tls_server_name"])
+		assert.Equal("h2", entry["tls_alpn"])
+		assert.Equal(false, entry["tls_resumed"])
+		assert.Equal("CN=test-client,O=FT", entry["client_cert_subject"])
+		assert.Equal("CN=test-client,O=FT", entry["client_cert_issuer"])
+		assert.Equal("4242", entry["client_cert_serial"])
+
+		assert.Equal(entries[0]["connection_id"], entry["connection_id"], "Both requests should share the connection")
+		assert.Equal(float64(i+1), entry["connection_requests"])
+		assert.Equal(i > 0, entry["connection_reused"])
+	}
+}
+
+func TestTLSDetailsNotLoggedByDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	ts := httptest.NewTLSServer(TransactionAwareRequestLoggingHandler(log, inner))
+	resp, err := ts.Client().Get(ts.URL + "/content")
+	assert.NoError(err)
+	resp.Body.Close()
+	ts.Close()
+
+	entries := logEntries(t, buf)
+	if !assert.Len(entries, 1) {
+		return
+	}
+	assert.NotContains(entries[0], "tls_version")
+	assert.NotContains(entries[0], "connection_id")
+}
@@ -0,0 +1,125 @@
+package httphandlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// DefaultTransactionIDMaxLength is the maximum length of the transaction IDs used when TransactionIDValidation doesn't set one.
+const DefaultTransactionIDMaxLength = 128
+
+// transactionIDPrefix is the prefix of the transaction IDs generated by transactionid-utils-go
+const transactionIDPrefix = "tid_"
+
+// TransactionIDPolicy is what the logging handler does with the requests whose transaction ID is invalid.
+type TransactionIDPolicy int
+
+const (
+	// ReplaceInvalidTransactionID replaces the invalid transaction ID with a new one.
+	ReplaceInvalidTransactionID TransactionIDPolicy = iota
+	// RejectInvalidTransactionID responds 400 Bad Request without calling the inner handler.
+	RejectInvalidTransactionID
+	// PrefixInvalidTransactionID keeps the invalid transaction ID stripped from the disallowed characters,
+	// truncated to the maximum length and prefixed with tid_ if needed.
+	PrefixInvalidTransactionID
+)
+
+// TransactionIDValidation describes the transaction IDs accepted in the X-Request-Id header.
+// When a transaction ID is replaced, the received value is logged quoted and truncated as original_transaction_id.
+type TransactionIDValidation struct {
+	// MaxLength is the maximum length of the transaction IDs, DefaultTransactionIDMaxLength if not set.
+	MaxLength int
+	// Allowed reports whether a character is allowed in the transaction IDs,
+	// by default letters, digits and the _ - . : characters.
+	Allowed func(r rune) bool
+	// RequirePrefix makes the transaction IDs not starting with tid_ invalid.
+	RequirePrefix bool
+	// Policy is what is done with invalid transaction IDs.
+	Policy TransactionIDPolicy
+}
+
+// ValidateTransactionIDs creates a handler option that validates the transaction IDs received in the
+// X-Request-Id header before they are used in the response header, the request context and the logs.
+func ValidateTransactionIDs(v TransactionIDValidation) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	if v.MaxLength <= 0 {
+		v.MaxLength = DefaultTransactionIDMaxLength
+	}
+	if v.Allowed == nil {
+		v.Allowed = transactionIDCharacter
+	}
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.transactionIDValidation = &v
+	}
+}
+
+// transactionIDCharacter reports whether r is allowed in transaction IDs by default
+func transactionIDCharacter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+		r == '_' || r == '-' || r == '.' || r == ':'
+}
+
+func (v *TransactionIDValidation) valid(id string) bool {
+	if len(id) > v.MaxLength || (v.RequirePrefix && !strings.HasPrefix(id, transactionIDPrefix)) {
+		return false
+	}
+	for _, r := range id {
+		if !v.Allowed(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalise returns the transaction ID replacing the invalid id according to the policy,
+// or an empty string if a new transaction ID has to be generated
+func (v *TransactionIDValidation) normalise(id string) string {
+	if v.Policy != PrefixInvalidTransactionID {
+		return ""
+	}
+	kept := strings.Map(func(r rune) rune {
+		if v.Allowed(r) {
+			return r
+		}
+		return -1
+	}, id)
+	if kept == "" {
+		return ""
+	}
+	if !strings.HasPrefix(kept, transactionIDPrefix) {
+		kept = transactionIDPrefix + kept
+	}
+	if len(kept) > v.MaxLength {
+		kept = kept[:v.MaxLength]
+	}
+	return kept
+}
+
+// transactionID returns the transaction ID of the request, generating one if the request has none.
+// If the received transaction ID is invalid, it also returns the received value, quoted and truncated for logging,
+// and whether the request has to be rejected. The X-Request-Id header of the request is set to the returned ID.
+func (h transactionAwareRequestLoggingHandler) transactionID(req *http.Request) (string, string, bool) {
+	received := h.receivedTransactionID(req)
+	v := h.transactionIDValidation
+	transactionID, original := received, ""
+	if received != "" && v != nil && !v.valid(received) {
+		transactionID = v.normalise(received)
+		original = received
+		if len(original) > v.MaxLength {
+			original = original[:v.MaxLength]
+		}
+		original = strconv.QuoteToASCII(original)
+	}
+	if transactionID == "" {
+		transactionID = h.newTransactionID(req)
+	}
+	req.Header.Set(transactionidutils.TransactionIDHeader, transactionID)
+	return transactionID, original, original != "" && v.Policy == RejectInvalidTransactionID
+}
+
+// rejectTransactionID is the handler of the requests rejected because of their transaction ID
+func rejectTransactionID(w http.ResponseWriter, _ *http.Request) {
+	http.Error(w, "invalid "+transactionidutils.TransactionIDHeader+" header", http.StatusBadRequest)
+}
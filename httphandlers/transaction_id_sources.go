@@ -0,0 +1,134 @@
+package httphandlers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	transactionidutils "github.com/Financial-Times/transactionid-utils-go"
+)
+
+// TraceparentHeader is the W3C Trace Context header. When it is one of the TransactionIDHeaders,
+// its trace ID prefixed with tid_ is used as the transaction ID.
+const TraceparentHeader = "Traceparent"
+
+// TransactionIDGenerator creates the transaction ID of a request which didn't provide one.
+type TransactionIDGenerator func(req *http.Request) string
+
+// GenerateTransactionIDs creates a handler option that replaces the generator of the transaction IDs,
+// used for the requests without a transaction ID and to replace the invalid ones.
+// By default the IDs are generated by transactionid-utils-go.
+func GenerateTransactionIDs(fn TransactionIDGenerator) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.transactionIDGenerator = fn
+	}
+}
+
+// TransactionIDHeaders creates a handler option that sets the request headers the transaction ID is read from,
+// in priority order. The default is X-Request-Id only, it has to be part of names to keep being accepted.
+// Whichever header it comes from, the transaction ID is passed on to the inner handler in X-Request-Id.
+func TransactionIDHeaders(names ...string) handlerOpt { // nolint:golint // we don't want handlerOpt exported
+	return func(h *transactionAwareRequestLoggingHandler) {
+		h.transactionIDHeaders = names
+	}
+}
+
+// receivedTransactionID returns the transaction ID of the first configured header the request has
+func (h transactionAwareRequestLoggingHandler) receivedTransactionID(req *http.Request) string {
+	if len(h.transactionIDHeaders) == 0 {
+		return req.Header.Get(transactionidutils.TransactionIDHeader)
+	}
+	for _, name := range h.transactionIDHeaders {
+		value := req.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		if http.CanonicalHeaderKey(name) == TraceparentHeader {
+			if traceID, ok := parseTraceparent(value); ok {
+				return transactionIDPrefix + traceID
+			}
+			continue
+		}
+		return value
+	}
+	return ""
+}
+
+// newTransactionID generates a transaction ID for the request
+func (h transactionAwareRequestLoggingHandler) newTransactionID(req *http.Request) string {
+	if h.transactionIDGenerator != nil {
+		return h.transactionIDGenerator(req)
+	}
+	return transactionidutils.NewTransactionID()
+}
+
+// parseTraceparent returns the trace ID of a W3C traceparent header value
+func parseTraceparent(value string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", false
+	}
+	traceID := parts[1]
+	if !isLowerHex(parts[0]) || !isLowerHex(traceID) || !isLowerHex(parts[2]) || traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	return traceID, true
+}
+
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// UUIDv7TransactionID generates tid_ prefixed version 7 UUIDs, which are ordered by creation time.
+func UUIDv7TransactionID(_ *http.Request) string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%s%x-%x-%x-%x-%x", transactionIDPrefix, b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// crockfordBase32 is the alphabet of ULIDs
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDTransactionID generates tid_ prefixed ULIDs, which are ordered by creation time.
+func ULIDTransactionID(_ *http.Request) string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(time.Now().UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var id [26]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockfordBase32[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return transactionIDPrefix + string(id[:])
+}
+
+// TraceTransactionID generates transaction IDs from the trace ID of the traceparent header of the request,
+// so the logs can be correlated with the traces. A random trace ID is used if the request has no valid traceparent.
+func TraceTransactionID(req *http.Request) string {
+	if traceID, ok := parseTraceparent(req.Header.Get(TraceparentHeader)); ok {
+		return transactionIDPrefix + traceID
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return transactionIDPrefix + hex.EncodeToString(b[:])
+}
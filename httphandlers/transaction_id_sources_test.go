@@ -0,0 +1,140 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionIDHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		expectedID string
+	}{
+		{
+			name:       "first header",
+			headers:    map[string]string{"X-Request-Id": "tid_request", "X-Correlation-Id": "tid_correlation"},
+			expectedID: "tid_request",
+		},
+		{
+			name:       "second header",
+			headers:    map[string]string{"X-Correlation-Id": "tid_correlation", "traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			expectedID: "tid_correlation",
+		},
+		{
+			name:       "traceparent",
+			headers:    map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			expectedID: "tid_4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:       "invalid traceparent",
+			headers:    map[string]string{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+			expectedID: "tid_generated",
+		},
+		{
+			name:       "none",
+			expectedID: "tid_generated",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			var innerID string
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				innerID = req.Header.Get("X-Request-Id")
+			})
+			req := httptest.NewRequest("GET", "/content", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler := TransactionAwareRequestLoggingHandler(log, inner,
+				TransactionIDHeaders("X-Request-Id", "X-Correlation-Id", "traceparent"),
+				GenerateTransactionIDs(func(*http.Request) string { return "tid_generated" }),
+			)
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(test.expectedID, w.Header().Get("X-Request-Id"))
+			assert.Equal(test.expectedID, innerID)
+			entries := logEntries(t, buf)
+			if assert.Len(entries, 1) {
+				assert.Equal(test.expectedID, entries[0]["transaction_id"])
+			}
+		})
+	}
+}
+
+func TestGeneratorReplacesInvalidTransactionIDs(t *testing.T) {
+	log := logger.NewUPPInfoLogger("test-service")
+	log.Out = new(bytes.Buffer)
+
+	req := httptest.NewRequest("GET", "/content", nil)
+	req.Header.Set("X-Request-Id", "invalid id")
+	w := httptest.NewRecorder()
+	handler := TransactionAwareRequestLoggingHandler(log, innerHandler{Status: http.StatusOK},
+		ValidateTransactionIDs(TransactionIDValidation{}),
+		GenerateTransactionIDs(UUIDv7TransactionID),
+	)
+	handler.ServeHTTP(w, req)
+
+	assert.Regexp(t, "^tid_"+uuidRegexp.String()+"$", w.Header().Get("X-Request-Id"))
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value           string
+		expectedTraceID string
+		expectedOK      bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", "4bf92f3577b34da6a3ce929d0e0e4736", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", "", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "", false},
+		{"00-4bf92f3577b34da6-00f067aa0ba902b7-01", "", false},
+		{"garbage", "", false},
+	}
+	for _, test := range tests {
+		traceID, ok := parseTraceparent(test.value)
+		assert.Equal(t, test.expectedOK, ok, test.value)
+		assert.Equal(t, test.expectedTraceID, traceID, test.value)
+	}
+}
+
+func TestTransactionIDGenerators(t *testing.T) {
+	assert := assert.New(t)
+
+	uuidv7 := regexp.MustCompile(`^tid_[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first := UUIDv7TransactionID(nil)
+	assert.Regexp(uuidv7, first)
+	time.Sleep(2 * time.Millisecond)
+	second := UUIDv7TransactionID(nil)
+	assert.Regexp(uuidv7, second)
+	assert.Less(first, second, "UUIDv7 transaction IDs should be ordered by creation time")
+
+	ulid := regexp.MustCompile(`^tid_[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+	first = ULIDTransactionID(nil)
+	assert.Regexp(ulid, first)
+	time.Sleep(2 * time.Millisecond)
+	second = ULIDTransactionID(nil)
+	assert.Regexp(ulid, second)
+	assert.Less(first, second, "ULID transaction IDs should be ordered by creation time")
+
+	req := httptest.NewRequest("GET", "/content", nil)
+	assert.Regexp(`^tid_[0-9a-f]{32}$`, TraceTransactionID(req))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal("tid_4bf92f3577b34da6a3ce929d0e0e4736", TraceTransactionID(req))
+}
@@ -0,0 +1,160 @@
+package httphandlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTransactionIDs(t *testing.T) {
+	tests := []struct {
+		name              string
+		validation        TransactionIDValidation
+		received          string
+		expectedID        string
+		expectedGenerated bool
+		expectedOriginal  string
+		expectedStatus    int
+	}{
+		{
+			name:           "valid",
+			received:       "tid_valid-1.2:3",
+			expectedID:     "tid_valid-1.2:3",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing",
+			received:       "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:              "log injection replaced",
+			received:          "tid_abc\n{\"level\":\"error\"}",
+			expectedGenerated: true,
+			expectedOriginal:  `"tid_abc\n{\"level\":\"error\"}"`,
+			expectedStatus:    http.StatusOK,
+		},
+		{
+			name:              "too long replaced with truncated original",
+			validation:        TransactionIDValidation{MaxLength: 8},
+			received:          "tid_0123456789",
+			expectedGenerated: true,
+			expectedOriginal:  `"tid_0123"`,
+			expectedStatus:    http.StatusOK,
+		},
+		{
+			name:           "prefix not required",
+			received:       "SOME-ID",
+			expectedID:     "SOME-ID",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "missing prefix added",
+			validation:       TransactionIDValidation{RequirePrefix: true, Policy: PrefixInvalidTransactionID},
+			received:         "SOME-ID",
+			expectedID:       "tid_SOME-ID",
+			expectedOriginal: `"SOME-ID"`,
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "disallowed characters stripped",
+			validation:       TransactionIDValidation{MaxLength: 12, Policy: PrefixInvalidTransactionID},
+			received:         "abc def\r\nghijkl",
+			expectedID:       "tid_abcdefgh",
+			expectedOriginal: `"abc def\r\nghi"`,
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:             "custom charset",
+			validation:       TransactionIDValidation{Allowed: func(r rune) bool { return r != '.' }},
+			received:         "tid_ünïcode",
+			expectedID:       "tid_ünïcode",
+			expectedOriginal: "",
+			expectedStatus:   http.StatusOK,
+		},
+		{
+			name:              "rejected",
+			validation:        TransactionIDValidation{Policy: RejectInvalidTransactionID},
+			received:          "tid_<script>",
+			expectedGenerated: true,
+			expectedOriginal:  `"tid_<script>"`,
+			expectedStatus:    http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			log := logger.NewUPPInfoLogger("test-service")
+			buf := new(bytes.Buffer)
+			log.Out = buf
+
+			var innerID string
+			inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				innerID = req.Header.Get("X-Request-Id")
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest("GET", "/content", nil)
+			if test.received != "" {
+				req.Header.Set("X-Request-Id", test.received)
+			}
+			w := httptest.NewRecorder()
+			TransactionAwareRequestLoggingHandler(log, inner, ValidateTransactionIDs(test.validation)).ServeHTTP(w, req)
+
+			assert.Equal(test.expectedStatus, w.Code)
+			transactionID := w.Header().Get("X-Request-Id")
+			switch {
+			case test.expectedID != "":
+				assert.Equal(test.expectedID, transactionID)
+			case test.expectedGenerated:
+				assert.NotEqual(test.received, transactionID)
+				assert.True(strings.HasPrefix(transactionID, "tid_"))
+			default:
+				assert.NotEmpty(transactionID)
+			}
+			if test.expectedStatus == http.StatusOK {
+				assert.Equal(transactionID, innerID, "The inner handler should see the validated transaction ID")
+			} else {
+				assert.Empty(innerID, "The inner handler should not be called")
+			}
+
+			entries := logEntries(t, buf)
+			if !assert.Len(entries, 1) {
+				return
+			}
+			assert.Equal(transactionID, entries[0]["transaction_id"])
+			if test.expectedOriginal != "" {
+				assert.Equal(test.expectedOriginal, entries[0]["original_transaction_id"])
+			} else {
+				assert.NotContains(entries[0], "original_transaction_id")
+			}
+		})
+	}
+}
+
+func TestOriginalTransactionIDNotOverridden(t *testing.T) {
+	assert := assert.New(t)
+
+	log := logger.NewUPPInfoLogger("test-service")
+	buf := new(bytes.Buffer)
+	log.Out = buf
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		AddLogField(req.Context(), "original_transaction_id", "forged")
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest("GET", "/content", nil)
+	req.Header.Set("X-Request-Id", "bad id")
+	TransactionAwareRequestLoggingHandler(log, inner, ValidateTransactionIDs(TransactionIDValidation{})).ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logEntries(t, buf)
+	if !assert.Len(entries, 1) {
+		return
+	}
+	assert.Equal(`"bad id"`, entries[0]["original_transaction_id"])
+}